@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sync"
+)
+
+// Group deduplicates concurrent calls that share a key so only one of them
+// does the work and the rest wait for its result.
+type Group struct {
+	mutex *sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+func NewGroup() *Group {
+	return &Group{
+		mutex: &sync.Mutex{},
+		calls: make(map[string]*call),
+	}
+}
+
+// Do runs fn once for every set of overlapping calls with the same key. The
+// shared result reports whether the value came from another caller's fn.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (value interface{}, shared bool, err error) {
+	g.mutex.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+
+		return c.value, true, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	defer func() {
+		g.mutex.Lock()
+		delete(g.calls, key)
+		g.mutex.Unlock()
+
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+
+	return c.value, false, c.err
+}
+
+// InFlight reports whether a call for key is currently running.
+func (g *Group) InFlight(key string) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	_, ok := g.calls[key]
+
+	return ok
+}
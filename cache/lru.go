@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded cache that evicts the least recently used entry and
+// expires every entry after its own TTL.
+type LRU struct {
+	mutex     *sync.Mutex
+	capacity  int
+	items     map[string]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+type Stats struct {
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+func NewLRU(capacity int) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &LRU{
+		mutex:    &sync.Mutex{},
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		c.removeElement(element)
+		c.misses++
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+
+	return entry.value, true
+}
+
+func (c *LRU) Set(key string, value interface{}, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	expires := time.Now().Add(ttl)
+
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(element)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *LRU) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *LRU) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		Size:      c.order.Len(),
+		Capacity:  c.capacity,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *LRU) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*lruEntry).key)
+}
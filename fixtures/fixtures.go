@@ -0,0 +1,255 @@
+// Package fixtures serves recorded upstream responses so the API works without
+// API keys or a network, and records live responses to build those fixtures.
+package fixtures
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/upstream"
+)
+
+const (
+	ModeLive    = "live"
+	ModeFixture = "fixture"
+	ModeRecord  = "record"
+
+	FixtureHeader = "X-Fixture"
+)
+
+// secretParams are stripped from fixture keys so recordings never contain
+// API keys and match regardless of which key is configured.
+var secretParams = []string{"apiKey", "api-key", "key"}
+
+//go:embed data
+var embedded embed.FS
+
+type Fixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"bodyBase64,omitempty"`
+}
+
+// Client replays fixtures in fixture mode, records responses from next into
+// dir in record mode and simply calls next in live mode.
+type Client struct {
+	mode string
+	next upstream.HTTPClient
+	dir  string
+}
+
+func IsValidMode(mode string) bool {
+	return mode == ModeLive || mode == ModeFixture || mode == ModeRecord
+}
+
+func NewClient(mode string, next upstream.HTTPClient, dir string) *Client {
+	return &Client{
+		mode: mode,
+		next: next,
+		dir:  dir,
+	}
+}
+
+func (c *Client) Get(reqURL string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return c.Do(req)
+}
+
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	switch c.mode {
+	case ModeFixture:
+		return c.replay(req), nil
+	case ModeRecord:
+		return c.record(req)
+	default:
+		return c.next.Do(req)
+	}
+}
+
+func (c *Client) replay(req *http.Request) *http.Response {
+	for _, name := range []string{fixtureName(req, true), fixtureName(req, false)} {
+		fixture, err := c.load(name)
+		if err == nil {
+			return fixture.response(req)
+		}
+
+		if !errors.IsNotFound(err) {
+			log.Errorf("Could not load fixture %s, %s", name, err.Error())
+		}
+	}
+
+	log.Warnf("No fixture for %s %s", req.Method, redactedURL(req.URL))
+
+	missing := Fixture{
+		Status: http.StatusNotFound,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   fmt.Sprintf(`{"status":"ERROR","error":"no fixture for %s %s"}`, req.Method, redactedURL(req.URL)),
+	}
+
+	return missing.response(req)
+}
+
+func (c *Client) record(req *http.Request) (*http.Response, error) {
+	res, err := c.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	fixture := Fixture{
+		Method: req.Method,
+		URL:    redactedURL(req.URL),
+		Status: res.StatusCode,
+		Header: res.Header,
+	}
+
+	if utf8.Valid(body) {
+		fixture.Body = string(body)
+	} else {
+		fixture.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+
+	err = c.save(fixtureName(req, true), fixture)
+	if err == nil {
+		// Keep the first recording for a path as the fallback for any query.
+		pathName := fixtureName(req, false)
+		if _, loadErr := c.load(pathName); errors.IsNotFound(loadErr) {
+			err = c.save(pathName, fixture)
+		}
+	}
+
+	if err != nil {
+		log.Errorf("Could not record fixture for %s, %s", fixture.URL, err.Error())
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return res, nil
+}
+
+// load prefers recordings on disk over the embedded fixtures.
+func (c *Client) load(name string) (Fixture, error) {
+	fixture := Fixture{}
+
+	var data []byte
+
+	err := os.ErrNotExist
+	if c.dir != "" {
+		data, err = ioutil.ReadFile(filepath.Join(c.dir, filepath.FromSlash(name)))
+	}
+
+	if os.IsNotExist(err) {
+		data, err = fs.ReadFile(embedded, path.Join("data", name))
+		if os.IsNotExist(err) {
+			return fixture, errors.NotFoundf("fixture %s", name)
+		}
+	}
+
+	if err != nil {
+		return fixture, errors.Trace(err)
+	}
+
+	err = json.Unmarshal(data, &fixture)
+
+	return fixture, errors.Annotatef(err, "invalid fixture %s", name)
+}
+
+func (c *Client) save(name string, fixture Fixture) error {
+	fixturePath := filepath.Join(c.dir, filepath.FromSlash(name))
+
+	err := os.MkdirAll(filepath.Dir(fixturePath), 0o755)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(ioutil.WriteFile(fixturePath, data, 0o644))
+}
+
+func (f *Fixture) response(req *http.Request) *http.Response {
+	body := []byte(f.Body)
+	if f.BodyBase64 != "" {
+		body, _ = base64.StdEncoding.DecodeString(f.BodyBase64)
+	}
+
+	header := http.Header{}
+	for name, values := range f.Header {
+		header[name] = append([]string(nil), values...)
+	}
+
+	header.Set(FixtureHeader, "true")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// fixtureName is host/method-hash.json, where the hash covers the path and,
+// when withQuery is set, the query without secret params.
+func fixtureName(req *http.Request, withQuery bool) string {
+	key := fmt.Sprintf("%s %s%s", req.Method, req.URL.Host, req.URL.EscapedPath())
+	if withQuery {
+		key = fmt.Sprintf("%s %s", req.Method, redactedURL(req.URL))
+	}
+
+	sum := sha1.Sum([]byte(key))
+	kind := "path"
+	if withQuery {
+		kind = "query"
+	}
+
+	return fmt.Sprintf("%s/%s-%s-%s.json", req.URL.Host, strings.ToLower(req.Method), kind, hex.EncodeToString(sum[:8]))
+}
+
+func redactedURL(reqURL *url.URL) string {
+	redacted := *reqURL
+	query := redacted.Query()
+
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+
+	redacted.RawQuery = query.Encode()
+
+	return redacted.String()
+}
@@ -0,0 +1,218 @@
+package googlebooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/y3sh/go143/cache"
+	"github.com/y3sh/go143/upstream"
+)
+
+const (
+	volumesURL     = "https://www.googleapis.com/books/v1/volumes"
+	MaxResults     = 40
+	searchCapacity = 1000
+	searchTTL      = time.Hour
+)
+
+type VolumesRes struct {
+	TotalItems int      `json:"totalItems"`
+	Items      []Volume `json:"items"`
+}
+
+type Volume struct {
+	ID         string     `json:"id"`
+	VolumeInfo VolumeInfo `json:"volumeInfo"`
+}
+
+type VolumeInfo struct {
+	Title               string               `json:"title"`
+	Subtitle            string               `json:"subtitle"`
+	Authors             []string             `json:"authors"`
+	Publisher           string               `json:"publisher"`
+	PublishedDate       string               `json:"publishedDate"`
+	Description         string               `json:"description"`
+	PageCount           int                  `json:"pageCount"`
+	Categories          []string             `json:"categories"`
+	IndustryIdentifiers []IndustryIdentifier `json:"industryIdentifiers"`
+	ImageLinks          struct {
+		SmallThumbnail string `json:"smallThumbnail"`
+		Thumbnail      string `json:"thumbnail"`
+	} `json:"imageLinks"`
+}
+
+type IndustryIdentifier struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+}
+
+type SimpleBook struct {
+	ID            string   `json:"id"`
+	Title         string   `json:"title"`
+	Subtitle      string   `json:"subtitle"`
+	Authors       []string `json:"authors"`
+	Publisher     string   `json:"publisher"`
+	PublishedDate string   `json:"publishedDate"`
+	Description   string   `json:"description"`
+	PageCount     int      `json:"pageCount"`
+	Categories    []string `json:"categories"`
+	Thumbnail     string   `json:"thumbnail"`
+	Isbn10        string   `json:"isbn10"`
+	Isbn13        string   `json:"isbn13"`
+}
+
+type SearchResult struct {
+	TotalItems int          `json:"totalItems"`
+	Offset     int          `json:"offset"`
+	Limit      int          `json:"limit"`
+	Books      []SimpleBook `json:"books"`
+}
+
+type Query struct {
+	Terms  string
+	Author string
+	Isbn   string
+	Offset int
+	Limit  int
+}
+
+type RestClient struct {
+	apiKey     string
+	searches   *cache.LRU
+	httpClient upstream.HTTPClient
+}
+
+func NewRestClient(apiKey string, httpClient upstream.HTTPClient) *RestClient {
+	return &RestClient{
+		apiKey:     apiKey,
+		searches:   cache.NewLRU(searchCapacity),
+		httpClient: httpClient,
+	}
+}
+
+// Search looks up volumes matching every field set in query.
+func (r *RestClient) Search(query Query) (SearchResult, error) {
+	searchURL := r.searchURL(query)
+	if cached, ok := r.searches.Get(searchURL); ok {
+		return cached.(SearchResult), nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, searchURL, nil)
+	if err != nil {
+		return SearchResult{}, errors.Trace(err)
+	}
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return SearchResult{}, errors.Annotate(err, "could not fetch google books volumes")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return SearchResult{}, errors.Errorf("non 200 status on google books volumes: %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return SearchResult{}, errors.Trace(err)
+	}
+
+	volumesRes := VolumesRes{}
+	err = json.Unmarshal(body, &volumesRes)
+	if err != nil {
+		return SearchResult{}, errors.Annotate(err, "could not unmarshal google books volumes")
+	}
+
+	result := SearchResult{
+		TotalItems: volumesRes.TotalItems,
+		Offset:     query.Offset,
+		Limit:      query.Limit,
+		Books:      []SimpleBook{},
+	}
+	for i := range volumesRes.Items {
+		result.Books = append(result.Books, volumesRes.Items[i].Simple())
+	}
+
+	r.searches.Set(searchURL, result, searchTTL)
+
+	return result, nil
+}
+
+func (r *RestClient) CacheStats() map[string]cache.Stats {
+	return map[string]cache.Stats{
+		"searches": r.searches.Stats(),
+	}
+}
+
+func (v *Volume) Simple() SimpleBook {
+	info := v.VolumeInfo
+	book := SimpleBook{
+		ID:            v.ID,
+		Title:         info.Title,
+		Subtitle:      info.Subtitle,
+		Authors:       info.Authors,
+		Publisher:     info.Publisher,
+		PublishedDate: info.PublishedDate,
+		Description:   info.Description,
+		PageCount:     info.PageCount,
+		Categories:    info.Categories,
+		Thumbnail:     strings.Replace(info.ImageLinks.Thumbnail, "http://", "https://", 1),
+	}
+
+	if book.Authors == nil {
+		book.Authors = []string{}
+	}
+
+	if book.Categories == nil {
+		book.Categories = []string{}
+	}
+
+	for _, identifier := range info.IndustryIdentifiers {
+		switch identifier.Type {
+		case "ISBN_10":
+			book.Isbn10 = identifier.Identifier
+		case "ISBN_13":
+			book.Isbn13 = identifier.Identifier
+		}
+	}
+
+	return book
+}
+
+func (r *RestClient) searchURL(query Query) string {
+	var terms []string
+	if query.Terms != "" {
+		terms = append(terms, query.Terms)
+	}
+
+	if query.Author != "" {
+		terms = append(terms, fmt.Sprintf("inauthor:%q", query.Author))
+	}
+
+	if query.Isbn != "" {
+		terms = append(terms, fmt.Sprintf("isbn:%s", query.Isbn))
+	}
+
+	limit := query.Limit
+	if limit < 1 || limit > MaxResults {
+		limit = MaxResults
+	}
+
+	params := url.Values{}
+	params.Set("q", strings.Join(terms, " "))
+	params.Set("startIndex", fmt.Sprint(query.Offset))
+	params.Set("maxResults", fmt.Sprint(limit))
+	params.Set("printType", "books")
+
+	if r.apiKey != "" {
+		params.Set("key", r.apiKey)
+	}
+
+	return fmt.Sprintf("%s?%s", volumesURL, params.Encode())
+}
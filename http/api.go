@@ -8,17 +8,26 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
 	"github.com/google/uuid"
 	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/cache"
+	"github.com/y3sh/go143/googlebooks"
 	"github.com/y3sh/go143/instagram"
+	"github.com/y3sh/go143/isbn"
 	"github.com/y3sh/go143/nytimes"
+	"github.com/y3sh/go143/polygon"
+	"github.com/y3sh/go143/projects"
+	"github.com/y3sh/go143/proxyURL"
 	"github.com/y3sh/go143/repository"
 	"github.com/y3sh/go143/twitter"
+	"github.com/y3sh/go143/upstream"
 )
 
 const (
@@ -27,15 +36,31 @@ const (
 	EchoURI                    = "/v1/form"
 	RandTweetURI               = "/v1/randTweet"
 	InstagramUserURI           = "/v1/instagram/users/{cseName}"
+	InstagramUserSearchPath    = "/search"
 	InstagramRandUserURI       = "/v1/instagram/users/random"
 	InstagramRandUserGenderURI = "/v1/instagram/users/random/{gender}"
 	InstagramSessionURI        = "/v1/instagram/sessions/{cseName}"
 	NYTimesBestSellersURI      = "/v1/nyTimes/bestSellers"
+	NYTimesMoversPath          = "/movers"
+	NYTimesListsURI            = "/v1/nyTimes/lists"
+	NYTimesBookHistoryURI      = "/v1/nyTimes/books/{isbn}/history"
+	NYTimesReviewsURI          = "/v1/nyTimes/reviews"
+	NYTimesArticlesURI         = "/v1/nyTimes/articles"
 	BookCoverURI               = "/v1/nyTimes/bookCovers/{isbn}"
+	BookCoverImagePath         = "/image"
 	FileUploadURI              = "/v1/files"
+	ProjectGroupURI            = "/v1/projects/{groupName}"
 	ProjectStoreURI            = "/v1/projects/{groupName}/{keyName}"
+	ProjectCollectionURI       = "/v1/projects/{groupName}/collections/{collectionName}"
+	ProjectCollectionItemPath  = "/{itemID}"
 	PolygonURI                 = "/v1/polygon"
+	StockSearchURI             = "/v1/stocks/search"
+	StockURI                   = "/v1/stocks/{ticker}"
+	StockQuotePath             = "/quote"
+	StockAggregatesPath        = "/aggregates"
 	GetProxyURI                = "/v1/getProxy/{url}"
+	StatusURI                  = "/v1/status"
+	BookSearchURI              = "/v1/books/search"
 )
 
 var (
@@ -44,17 +69,33 @@ var (
 		"https://go143.y3sh.com/v1/tweets",
 		"https://go143.y3sh.com/v1/form",
 		"https://go143.y3sh.com/v1/randTweet",
-		"https://go143.y3sh.com/v1/nyTimes/bestSellers",
+		"https://go143.y3sh.com/v1/nyTimes/bestSellers?list={listName}&date={YYYY-MM-DD}&format={json|csv}",
+		"https://go143.y3sh.com/v1/nyTimes/bestSellers/movers?list={listName}&date={YYYY-MM-DD}&limit={limit}",
+		"https://go143.y3sh.com/v1/nyTimes/lists",
+		"https://go143.y3sh.com/v1/nyTimes/books/{isbn}/history",
+		"https://go143.y3sh.com/v1/nyTimes/reviews?isbn={isbn}|title={title}|author={author}",
+		"https://go143.y3sh.com/v1/nyTimes/articles?q={query}&page={page}",
 		"https://go143.y3sh.com/v1/nyTimes/bookCovers/{isbn}",
+		"https://go143.y3sh.com/v1/nyTimes/bookCovers/{isbn}/image?width={64|128|256|512}",
 		"https://go143.y3sh.com/v1/instagram/users/{cseName}",
+		"https://go143.y3sh.com/v1/instagram/users/{cseName}/search?q={query}&limit={limit}&offset={offset}",
 		"https://go143.y3sh.com/v1/instagram/users/random",
 		"https://go143.y3sh.com/v1/instagram/users/random/{gender}",
 		"https://go143.y3sh.com/v1/instagram/session",
+		"https://go143.y3sh.com/v1/projects/TheATeam?limit={limit}&offset={offset}",
 		"https://go143.y3sh.com/v1/projects/TheATeam/posts",
-		"https://go143.y3sh.com/v1/polygon/{path}",
+		"https://go143.y3sh.com/v1/projects/TheATeam/collections/posts?sort={field|-field}&limit={limit}&offset={offset}",
+		"https://go143.y3sh.com/v1/projects/TheATeam/collections/posts/{id}",
+		"https://go143.y3sh.com/v1/polygon/{polygonRoute}",
+		"https://go143.y3sh.com/v1/stocks/{ticker}",
+		"https://go143.y3sh.com/v1/stocks/{ticker}/quote",
+		"https://go143.y3sh.com/v1/stocks/{ticker}/aggregates?from={YYYY-MM-DD}&to={YYYY-MM-DD}&timespan={timespan}&multiplier={multiplier}&format={json|csv}",
+		"https://go143.y3sh.com/v1/stocks/search?q={query}&limit={limit}",
 		"https://go143.y3sh.com/v1/files",
 		"https://go143.y3sh.com/v1/getProxy/{encodeURL}",
-	}}
+		"https://go143.y3sh.com/v1/status",
+		"https://go143.y3sh.com/v1/books/search?q={terms}&author={author}&isbn={isbn}&limit={limit}&offset={offset}",
+	}, polygon.Routes}
 )
 
 type API struct {
@@ -64,8 +105,12 @@ type API struct {
 	ProjectStoreService  ProjectStoreService
 	S3Repository         S3Repository
 	NyTimesClient        NyTimesClient
+	GoogleBooksClient    GoogleBooksClient
 	PolygonClient        PolygonClient
+	StockService         StockService
 	ProxyURLClient       ProxyURLClient
+	UpstreamBreakers     UpstreamBreakers
+	HTTPCache            HTTPCache
 }
 
 type Router interface {
@@ -83,55 +128,111 @@ type TweetService interface {
 type InstagramUserService interface {
 	AddUser(cseName string, user instagram.User) error
 	GetUsers(string) []instagram.User
+	SearchUsers(cseName, query string, limit, offset int) []instagram.PublicUser
 	GetRandProfile() instagram.RandomUser
 	GetRandProfileByGender(gender string) instagram.RandomUser
 	IsValidPassword(username string, passwordAttempt string, password string) bool
 }
 
 type NyTimesClient interface {
-	GetSimpleBestSellers() []nytimes.SimpleBook
+	GetSimpleBestSellers(listName, date string) []nytimes.SimpleBook
+	GetBookHistory(isbn string) nytimes.BookHistory
+	GetMovers(listName, date string, limit int) nytimes.Movers
+	GetReviews(query nytimes.ReviewQuery) ([]nytimes.SimpleReview, error)
+	SearchArticles(query string, page int) (nytimes.ArticleSearchResult, error)
+	GetListNames() []nytimes.SimpleList
 	GetBookCoverURL(isbn string) nytimes.BookCoverURL
+	GetBookCoverImage(isbn string, width int) (nytimes.CoverImage, error)
+	CacheStats() map[string]cache.Stats
+}
+
+type GoogleBooksClient interface {
+	Search(query googlebooks.Query) (googlebooks.SearchResult, error)
+	CacheStats() map[string]cache.Stats
 }
 
 type PolygonClient interface {
-	GetPolygonPath(path string) []byte
+	GetPolygonPath(path string) (*polygon.Response, error)
+}
+
+type StockService interface {
+	GetQuote(ticker string) (polygon.Quote, error)
+	GetAggregates(query polygon.AggregatesQuery) (polygon.Aggregates, error)
+	GetTickerDetails(ticker string) (polygon.TickerDetails, error)
+	SearchTickers(query string, limit int) ([]polygon.TickerDetails, error)
 }
 
 type ProxyURLClient interface {
-	GetProxyURL(url string) []byte
+	Proxy(method, url string, header http.Header, body io.Reader) (*proxyURL.Response, error)
+	Methods() []string
 }
 
 type ProjectStoreService interface {
 	GetValue(groupName, keyName string) string
 	SetValue(groupName, keyName, value string)
+	ListKeys(groupName string, limit, offset int) (projects.KeyList, error)
+	DeleteKey(groupName, keyName string) error
+	DeleteGroup(groupName string) (int64, error)
+	AddItem(groupName, collection string, item projects.Item) (projects.Item, error)
+	ListItems(groupName, collection string, query projects.ItemQuery) (projects.ItemList, error)
+	GetItem(groupName, collection, id string) (projects.Item, error)
+	ReplaceItem(groupName, collection, id string, item projects.Item) (projects.Item, error)
+	PatchItem(groupName, collection, id string, patch projects.Item) (projects.Item, error)
+	DeleteItem(groupName, collection, id string) error
+}
+
+type UpstreamBreakers interface {
+	Statuses() map[string]upstream.BreakerStatus
+}
+
+type HTTPCache interface {
+	Stats() cache.Stats
 }
 
 type S3Repository interface {
 	AddFileToS3(name string, reader *bytes.Reader) (string, error)
 }
 
+type DeletedCount struct {
+	Deleted int64 `json:"deleted"`
+}
+
+type Status struct {
+	Caches   map[string]cache.Stats            `json:"caches"`
+	Breakers map[string]upstream.BreakerStatus `json:"breakers"`
+}
+
 type APIVersion struct {
-	API     string   `json:"api"`
-	Version string   `json:"version"`
-	URLS    []string `json:"urls"`
+	API           string           `json:"api"`
+	Version       string           `json:"version"`
+	URLS          []string         `json:"urls"`
+	PolygonRoutes []*polygon.Route `json:"polygonRoutes"`
 }
 
 func NewAPIRouter(httpRouter Router, tweetService TweetService,
 	instagramUserService InstagramUserService,
 	nyTimesClient NyTimesClient,
+	googleBooksClient GoogleBooksClient,
 	polygonClient PolygonClient,
+	stockService StockService,
 	proxyURLClient ProxyURLClient,
 	projectStoreService ProjectStoreService,
-	s3Repository S3Repository) *API {
+	s3Repository S3Repository,
+	upstreamBreakers UpstreamBreakers,
+	httpCache HTTPCache) *API {
 	a := &API{
 		Router:               httpRouter,
 		TweetService:         tweetService,
 		InstagramUserService: instagramUserService,
 		NyTimesClient:        nyTimesClient,
+		GoogleBooksClient:    googleBooksClient,
 		PolygonClient:        polygonClient,
+		StockService:         stockService,
 		ProxyURLClient:       proxyURLClient,
 		ProjectStoreService:  projectStoreService,
 		S3Repository:         s3Repository,
+		UpstreamBreakers:     upstreamBreakers,
+		HTTPCache:            httpCache,
 	}
 
 	a.EnableCORS()
@@ -174,6 +275,7 @@ func NewAPIRouter(httpRouter Router, tweetService TweetService,
 	httpRouter.Route(InstagramUserURI, func(r chi.Router) {
 		r.Post("/", a.PostInstagramUser)
 		r.Get("/", a.GetInstagramUsers)
+		r.Get(InstagramUserSearchPath, a.SearchInstagramUsers)
 	})
 
 	httpRouter.Route(InstagramRandUserURI, func(r chi.Router) {
@@ -190,29 +292,84 @@ func NewAPIRouter(httpRouter Router, tweetService TweetService,
 
 	httpRouter.Route(NYTimesBestSellersURI, func(r chi.Router) {
 		r.Get("/", a.GetNyTimesBestSellers)
+		r.Get(NYTimesMoversPath, a.GetNyTimesMovers)
+	})
+
+	httpRouter.Route(NYTimesListsURI, func(r chi.Router) {
+		r.Get("/", a.GetNyTimesLists)
+	})
+
+	httpRouter.Route(NYTimesBookHistoryURI, func(r chi.Router) {
+		r.Get("/", a.GetNyTimesBookHistory)
+	})
+
+	httpRouter.Route(NYTimesReviewsURI, func(r chi.Router) {
+		r.Get("/", a.GetNyTimesReviews)
+	})
+
+	httpRouter.Route(NYTimesArticlesURI, func(r chi.Router) {
+		r.Get("/", a.GetNyTimesArticles)
 	})
 
 	httpRouter.Route(BookCoverURI, func(r chi.Router) {
 		r.Get("/", a.GetNyTimesBookCover)
+		r.Get(BookCoverImagePath, a.GetNyTimesBookCoverImage)
+	})
+
+	httpRouter.Route(BookSearchURI, func(r chi.Router) {
+		r.Get("/", a.GetBookSearch)
 	})
 
 	httpRouter.Route(PolygonURI, func(r chi.Router) {
 		r.Get("/*", a.GetPolygon)
 	})
 
+	httpRouter.Route(StockSearchURI, func(r chi.Router) {
+		r.Get("/", a.GetStockSearch)
+	})
+
+	httpRouter.Route(StockURI, func(r chi.Router) {
+		r.Get("/", a.GetStockDetails)
+		r.Get(StockQuotePath, a.GetStockQuote)
+		r.Get(StockAggregatesPath, a.GetStockAggregates)
+	})
+
 	httpRouter.Route(GetProxyURI, func(r chi.Router) {
-		r.Get("/*", a.GetProxyURL)
+		r.Get("/*", a.ProxyURL)
+		r.Post("/*", a.ProxyURL)
+		r.Put("/*", a.ProxyURL)
+		r.Patch("/*", a.ProxyURL)
+		r.Delete("/*", a.ProxyURL)
+	})
+
+	httpRouter.Route(ProjectGroupURI, func(r chi.Router) {
+		r.Get("/", a.GetProjectKeys)
+		r.Delete("/", a.DeleteProjectGroup)
+	})
+
+	httpRouter.Route(ProjectCollectionURI, func(r chi.Router) {
+		r.Get("/", a.GetCollectionItems)
+		r.Post("/", a.AddCollectionItem)
+		r.Get(ProjectCollectionItemPath, a.GetCollectionItem)
+		r.Put(ProjectCollectionItemPath, a.ReplaceCollectionItem)
+		r.Patch(ProjectCollectionItemPath, a.PatchCollectionItem)
+		r.Delete(ProjectCollectionItemPath, a.DeleteCollectionItem)
 	})
 
 	httpRouter.Route(ProjectStoreURI, func(r chi.Router) {
 		r.Get("/", a.GetProjectKeyValue)
 		r.Post("/", a.SetProjectKeyValue)
+		r.Delete("/", a.DeleteProjectKeyValue)
 	})
 
 	httpRouter.Route(FileUploadURI, func(r chi.Router) {
 		r.Post("/", a.PostFileUpload)
 	})
 
+	httpRouter.Route(StatusURI, func(r chi.Router) {
+		r.Get("/", a.GetStatus)
+	})
+
 	http.Handle(SiteRoot, httpRouter)
 
 	return a
@@ -286,6 +443,29 @@ func (a *API) GetInstagramUsers(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, r, users)
 }
 
+func (a *API) SearchInstagramUsers(w http.ResponseWriter, r *http.Request) {
+	cseName := chi.URLParam(r, "cseName")
+	if cseName == "" {
+		WriteBadRequest(w, r, "Missing CSE Name")
+		return
+	}
+
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		WriteBadRequest(w, r, "Missing search query q")
+		return
+	}
+
+	limit, offset, err := ParseLimitOffset(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	users := a.InstagramUserService.SearchUsers(cseName, query, limit, offset)
+	WriteJSON(w, r, users)
+}
+
 func (a *API) PostInstagramSession(w http.ResponseWriter, r *http.Request) {
 	cseName := chi.URLParam(r, "cseName")
 	if cseName == "" {
@@ -314,6 +494,27 @@ func (a *API) GetRoot(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, r, apiVersion)
 }
 
+func (a *API) GetStatus(w http.ResponseWriter, r *http.Request) {
+	status := Status{
+		Caches: make(map[string]cache.Stats),
+	}
+
+	for name, stats := range a.NyTimesClient.CacheStats() {
+		status.Caches["nyTimes."+name] = stats
+	}
+
+	for name, stats := range a.GoogleBooksClient.CacheStats() {
+		status.Caches["googleBooks."+name] = stats
+	}
+
+	// Proxied and Polygon responses share one HTTP cache.
+	status.Caches["http"] = a.HTTPCache.Stats()
+
+	status.Breakers = a.UpstreamBreakers.Statuses()
+
+	WriteJSON(w, r, status)
+}
+
 func (a *API) GetTweets(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, r, a.TweetService.GetTweets())
 }
@@ -353,47 +554,513 @@ func (a *API) GetRandTweet(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *API) GetNyTimesBestSellers(w http.ResponseWriter, r *http.Request) {
-	bestSellers := a.NyTimesClient.GetSimpleBestSellers()
+	listName, date, ok := bestSellerParams(w, r)
+	if !ok {
+		return
+	}
+
+	bestSellers := a.NyTimesClient.GetSimpleBestSellers(listName, date)
+
+	if WantsCSV(w, r) {
+		if date == "" {
+			date = "current"
+		}
+
+		WriteBestSellersCSV(w, r, csvFilename("bestsellers", listName, date), bestSellers)
+
+		return
+	}
 
 	WriteJSON(w, r, bestSellers)
 }
 
+func (a *API) GetNyTimesMovers(w http.ResponseWriter, r *http.Request) {
+	listName, date, ok := bestSellerParams(w, r)
+	if !ok {
+		return
+	}
+
+	limit, _, err := ParseLimitOffset(r, nytimes.DefaultMoversLimit, maxPageLimit)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	WriteJSON(w, r, a.NyTimesClient.GetMovers(listName, date, limit))
+}
+
+// bestSellerParams reads the list and date query params, defaulting to the
+// current hardcover fiction list.
+func bestSellerParams(w http.ResponseWriter, r *http.Request) (listName, date string, ok bool) {
+	listName = r.URL.Query().Get("list")
+	if listName == "" {
+		listName = nytimes.DefaultListName
+	}
+
+	if !nytimes.IsValidListName(listName) {
+		WriteBadRequest(w, r, "Invalid list name, see /v1/nyTimes/lists")
+		return "", "", false
+	}
+
+	date = r.URL.Query().Get("date")
+	if date != "" {
+		publishedDate, err := time.Parse(nytimes.DateFormat, date)
+		if err != nil || publishedDate.After(time.Now()) {
+			WriteBadRequest(w, r, "Invalid date, expected a past YYYY-MM-DD")
+			return "", "", false
+		}
+	}
+
+	return listName, date, true
+}
+
+func (a *API) GetNyTimesBookHistory(w http.ResponseWriter, r *http.Request) {
+	bookIsbn, ok := isbnParam(w, r)
+	if !ok {
+		return
+	}
+
+	WriteJSON(w, r, a.NyTimesClient.GetBookHistory(bookIsbn))
+}
+
+func (a *API) GetNyTimesLists(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, r, a.NyTimesClient.GetListNames())
+}
+
+func (a *API) GetNyTimesReviews(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := nytimes.ReviewQuery{
+		Title:  strings.TrimSpace(params.Get("title")),
+		Author: strings.TrimSpace(params.Get("author")),
+	}
+
+	if isbnStr := params.Get("isbn"); isbnStr != "" {
+		bookIsbn, err := isbn.To13(isbnStr)
+		if err != nil {
+			WriteBadRequest(w, r, "Invalid ISBN-10 or ISBN-13")
+			return
+		}
+
+		query.Isbn = bookIsbn
+	}
+
+	if query.Isbn == "" && query.Title == "" && query.Author == "" {
+		WriteBadRequest(w, r, "One of isbn, title or author is required")
+		return
+	}
+
+	reviews, err := a.NyTimesClient.GetReviews(query)
+	if err != nil {
+		WriteUpstreamError(w, r, "Reviews unavailable", err)
+
+		return
+	}
+
+	WriteJSON(w, r, reviews)
+}
+
+func (a *API) GetNyTimesArticles(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		WriteBadRequest(w, r, "Missing search query q")
+		return
+	}
+
+	page := 0
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		var err error
+
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 0 || page > nytimes.MaxArticlePage {
+			WriteBadRequest(w, r, fmt.Sprintf("Page must be 0-%d", nytimes.MaxArticlePage))
+			return
+		}
+	}
+
+	articles, err := a.NyTimesClient.SearchArticles(query, page)
+	if err != nil {
+		WriteUpstreamError(w, r, "Article search unavailable", err)
+
+		return
+	}
+
+	WriteJSON(w, r, articles)
+}
+
 func (a *API) GetNyTimesBookCover(w http.ResponseWriter, r *http.Request) {
-	isbn := chi.URLParam(r, "isbn")
-	coverURL := a.NyTimesClient.GetBookCoverURL(isbn)
+	bookIsbn, ok := isbnParam(w, r)
+	if !ok {
+		return
+	}
+
+	coverURL := a.NyTimesClient.GetBookCoverURL(bookIsbn)
 
 	WriteJSON(w, r, coverURL)
 }
 
-func (a *API) GetPolygon(w http.ResponseWriter, r *http.Request) {
-	polygonPath := strings.Replace(r.RequestURI, "/v1/polygon/", "", 1)
+func (a *API) GetNyTimesBookCoverImage(w http.ResponseWriter, r *http.Request) {
+	bookIsbn, ok := isbnParam(w, r)
+	if !ok {
+		return
+	}
+
+	width := 0
+	if widthStr := r.URL.Query().Get("width"); widthStr != "" {
+		var err error
+
+		width, err = strconv.Atoi(widthStr)
+		if err != nil || !nytimes.IsValidCoverWidth(width) {
+			WriteBadRequest(w, r, fmt.Sprintf("Width must be one of %v", nytimes.CoverWidths))
+			return
+		}
+	}
+
+	cover, err := a.NyTimesClient.GetBookCoverImage(bookIsbn, width)
+	if err != nil {
+		WriteUpstreamError(w, r, "Cover image unavailable", err)
 
-	polyBytes := a.PolygonClient.GetPolygonPath(polygonPath)
-	if len(polyBytes) > 0 {
-		WriteResponse(w, r, polyBytes)
+		return
+	}
+
+	w.Header().Set("content-type", cover.ContentType)
+
+	if cover.IsPlaceholder() {
+		// The placeholder may stand in during a Google Books outage, so
+		// clients should ask again soon for the real cover.
+		w.Header().Set("cache-control", "public, max-age=300")
 	} else {
-		WriteError(w, r, "Poly error", 400)
+		w.Header().Set("cache-control", "public, max-age=604800")
+	}
+
+	WriteResponse(w, r, cover.Data)
+}
+
+func (a *API) GetBookSearch(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := googlebooks.Query{
+		Terms:  strings.TrimSpace(params.Get("q")),
+		Author: strings.TrimSpace(params.Get("author")),
+		Isbn:   isbn.Normalize(params.Get("isbn")),
+	}
+
+	if query.Terms == "" && query.Author == "" && query.Isbn == "" {
+		WriteBadRequest(w, r, "One of q, author or isbn is required")
+		return
+	}
+
+	if query.Isbn != "" && !isbn.IsValid(query.Isbn) {
+		WriteBadRequest(w, r, "Invalid ISBN-10 or ISBN-13")
+		return
+	}
+
+	var err error
+
+	query.Limit, query.Offset, err = ParseLimitOffset(r, defaultPageLimit, googlebooks.MaxResults)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	result, err := a.GoogleBooksClient.Search(query)
+	if err != nil {
+		WriteUpstreamError(w, r, "Book search unavailable", err)
+
+		return
+	}
+
+	WriteJSON(w, r, result)
+}
+
+// isbnParam reads the {isbn} path param as an ISBN-13, writing a bad request
+// when it is not a valid ISBN-10 or ISBN-13.
+func isbnParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	bookIsbn, err := isbn.To13(chi.URLParam(r, "isbn"))
+	if err != nil {
+		WriteBadRequest(w, r, "Invalid ISBN-10 or ISBN-13")
+		return "", false
+	}
+
+	return bookIsbn, true
+}
+
+func (a *API) GetPolygon(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Del("apiKey")
+	query.Del("format")
+
+	route := chi.URLParam(r, "*")
+
+	matched, err := polygon.MatchRoute(route, query)
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	wantsCSV := WantsCSV(w, r)
+	if wantsCSV && !polygon.IsAggregatesRoute(matched) {
+		WriteError(w, r, "CSV is only available for aggregates routes", http.StatusNotAcceptable)
+		return
+	}
+
+	polygonPath := route
+	if len(query) > 0 {
+		polygonPath = fmt.Sprintf("%s?%s", route, query.Encode())
+	}
+
+	polyRes, err := a.PolygonClient.GetPolygonPath(polygonPath)
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	if wantsCSV && polyRes.StatusCode == http.StatusOK {
+		bars, err := polygon.ParseAggregateBars(polyRes.Body)
+		if err != nil {
+			WritePolygonError(w, r, err)
+			return
+		}
+
+		WriteBarsCSV(w, r, csvFilename(strings.TrimPrefix(route, "v2/aggs/")), bars)
+
+		return
+	}
+
+	for name, values := range polyRes.Header {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(polyRes.StatusCode)
+	WriteResponse(w, r, polyRes.Body)
+}
+
+func (a *API) GetStockQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := a.StockService.GetQuote(chi.URLParam(r, "ticker"))
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, quote)
+}
+
+func (a *API) GetStockDetails(w http.ResponseWriter, r *http.Request) {
+	details, err := a.StockService.GetTickerDetails(chi.URLParam(r, "ticker"))
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, details)
+}
+
+func (a *API) GetStockAggregates(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := polygon.AggregatesQuery{
+		Ticker:     chi.URLParam(r, "ticker"),
+		Multiplier: 1,
+		Timespan:   params.Get("timespan"),
+		From:       params.Get("from"),
+		To:         params.Get("to"),
+	}
+
+	if query.From == "" || query.To == "" {
+		WriteBadRequest(w, r, "from and to dates are required")
+		return
+	}
+
+	if query.Timespan == "" {
+		query.Timespan = "day"
+	}
+
+	if multiplier := params.Get("multiplier"); multiplier != "" {
+		var err error
+
+		query.Multiplier, err = strconv.Atoi(multiplier)
+		if err != nil {
+			WriteBadRequest(w, r, "multiplier must be an integer")
+			return
+		}
 	}
+
+	aggregates, err := a.StockService.GetAggregates(query)
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	if WantsCSV(w, r) {
+		bars := make([]polygon.TickerBar, 0, len(aggregates.Bars))
+		for _, bar := range aggregates.Bars {
+			bars = append(bars, polygon.TickerBar{Ticker: aggregates.Ticker, Bar: bar})
+		}
+
+		WriteBarsCSV(w, r, csvFilename(aggregates.Ticker, strconv.Itoa(aggregates.Multiplier)+aggregates.Timespan,
+			aggregates.From, aggregates.To), bars)
+
+		return
+	}
+
+	WriteJSON(w, r, aggregates)
+}
+
+func (a *API) GetStockSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		WriteBadRequest(w, r, "Missing search query q")
+		return
+	}
+
+	limit, _, err := ParseLimitOffset(r, polygon.DefaultSearchLimit, polygon.MaxSearchLimit)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	tickers, err := a.StockService.SearchTickers(query, limit)
+	if err != nil {
+		WritePolygonError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, tickers)
+}
+
+// WriteUpstreamError writes a 503 with Retry-After while the upstream's
+// circuit breaker is open and a 502 for any other upstream failure.
+func WriteUpstreamError(w http.ResponseWriter, r *http.Request, userMessage string, err error) {
+	if openErr, ok := upstream.AsCircuitOpen(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(openErr.RetryAfterSeconds()))
+		WriteError(w, r, userMessage, http.StatusServiceUnavailable)
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"method": r.Method,
+		"url":    r.URL,
+	}).Errorf("%s \n%+v\n", userMessage, err)
+
+	WriteError(w, r, userMessage, http.StatusBadGateway)
 }
 
-func (a *API) GetProxyURL(w http.ResponseWriter, r *http.Request) {
-	proxyURL, err := url.QueryUnescape(chi.URLParam(r, "url"))
+// WritePolygonError maps errors from the Polygon client and stock service to
+// the matching HTTP status without exposing upstream details.
+func WritePolygonError(w http.ResponseWriter, r *http.Request, err error) {
+	cause := errors.Cause(err)
+
+	if rateLimitErr, ok := cause.(*polygon.RateLimitError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(rateLimitErr.RetryAfterSeconds()))
+		WriteError(w, r, "Polygon request budget exhausted, try again later", http.StatusTooManyRequests)
+
+		return
+	}
+
+	if statusErr, ok := cause.(*polygon.UpstreamStatusError); ok && statusErr.StatusCode == http.StatusTooManyRequests {
+		if retryAfter := statusErr.Header.Get("Retry-After"); retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+
+		WriteError(w, r, "Polygon rate limit reached, try again later", http.StatusTooManyRequests)
+
+		return
+	}
+
+	if _, ok := upstream.AsCircuitOpen(err); ok {
+		WriteUpstreamError(w, r, "Polygon unavailable", err)
+		return
+	}
+
+	switch {
+	case errors.IsForbidden(err):
+		WriteError(w, r, err.Error(), http.StatusForbidden)
+	case errors.IsBadRequest(err):
+		WriteBadRequest(w, r, err.Error())
+	case errors.IsNotFound(err):
+		WriteError(w, r, "Not found", http.StatusNotFound)
+	case polygon.IsTimeout(err):
+		log.Errorf("Polygon request timed out \n%+v\n", err)
+		WriteError(w, r, "Polygon timed out", http.StatusGatewayTimeout)
+	default:
+		log.Errorf("Polygon request failed \n%+v\n", err)
+		WriteError(w, r, "Polygon unavailable", http.StatusBadGateway)
+	}
+}
+
+func (a *API) ProxyURL(w http.ResponseWriter, r *http.Request) {
+	targetURL, err := url.QueryUnescape(chi.URLParam(r, "url"))
 	if err != nil {
 		WriteError(w, r, "Bad URL", 400)
 		return
 	}
 
-	polyBytes := a.ProxyURLClient.GetProxyURL(proxyURL)
-	if len(polyBytes) > 0 {
-		WriteResponse(w, r, polyBytes)
-	} else {
-		WriteError(w, r, "Proxy error", 400)
+	proxyRes, err := a.ProxyURLClient.Proxy(r.Method, targetURL, r.Header, r.Body)
+	if err != nil {
+		if errors.IsMethodNotAllowed(err) {
+			w.Header().Set("Allow", strings.Join(a.ProxyURLClient.Methods(), ", "))
+		}
+
+		WriteProxyError(w, r, err)
+		return
+	}
+	defer proxyRes.Body.Close()
+
+	for name, values := range proxyRes.Header {
+		w.Header()[name] = values
+	}
+
+	w.WriteHeader(proxyRes.StatusCode)
+
+	bytesWritten, err := io.Copy(w, proxyRes.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"method":       r.Method,
+			"url":          r.URL,
+			"bytesWritten": bytesWritten,
+		}).Errorf("Proxy stream aborted. \n%+v\n", err)
+
+		// The status line is already sent, so abort the connection rather than
+		// let the client treat a truncated body as complete.
+		panic(http.ErrAbortHandler)
+	}
+
+	log.WithFields(log.Fields{
+		"method":       r.Method,
+		"url":          r.URL,
+		"bytesWritten": bytesWritten,
+		"httpCode":     proxyRes.StatusCode,
+	}).Info("HTTP proxy response sent.")
+}
+
+// WriteProxyError maps errors from the proxy client to the matching HTTP
+// status.
+func WriteProxyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch cause := errors.Cause(err).(type) {
+	case *proxyURL.TooLargeError:
+		WriteError(w, r, cause.Error(), http.StatusBadGateway)
+		return
+	case *proxyURL.RequestTooLargeError:
+		WriteError(w, r, cause.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	switch {
+	case errors.IsMethodNotAllowed(err):
+		WriteError(w, r, err.Error(), http.StatusMethodNotAllowed)
+	case errors.IsForbidden(err):
+		WriteError(w, r, err.Error(), http.StatusForbidden)
+	case errors.IsNotValid(err):
+		WriteError(w, r, "Bad URL", http.StatusBadRequest)
+	default:
+		log.Errorf("Proxy request failed \n%+v\n", err)
+		WriteError(w, r, "Proxy error", http.StatusBadGateway)
 	}
 }
 
 func (a *API) GetProjectKeyValue(w http.ResponseWriter, r *http.Request) {
-	groupName := chi.URLParam(r, "groupName")
-	keyName := chi.URLParam(r, "keyName")
+	groupName, keyName, ok := keyParams(w, r)
+	if !ok {
+		return
+	}
 
 	val := a.ProjectStoreService.GetValue(groupName, keyName)
 
@@ -422,14 +1089,279 @@ func (a *API) SetProjectKeyValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	groupName, keyName, ok := keyParams(w, r)
+	if !ok {
+		return
+	}
+
+	a.ProjectStoreService.SetValue(groupName, keyName, string(bodyBytes))
+
+	WriteJSON(w, r, OK)
+}
+
+func (a *API) DeleteProjectKeyValue(w http.ResponseWriter, r *http.Request) {
+	groupName, keyName, ok := keyParams(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.ProjectStoreService.DeleteKey(groupName, keyName)
+	if errors.IsNotFound(err) {
+		WriteError(w, r, "Key not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, OK)
+}
+
+func (a *API) GetProjectKeys(w http.ResponseWriter, r *http.Request) {
+	groupName, ok := groupNameParam(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := ParseLimitOffset(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	keyList, err := a.ProjectStoreService.ListKeys(groupName, limit, offset)
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, keyList)
+}
+
+// DeleteProjectGroup deletes every key in a group. The group name must be
+// repeated in the confirm param so a stray request can't wipe a project.
+func (a *API) DeleteProjectGroup(w http.ResponseWriter, r *http.Request) {
+	groupName, ok := groupNameParam(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("confirm") != groupName {
+		WriteBadRequest(w, r, fmt.Sprintf("Pass confirm=%s to delete every key in the group", groupName))
+		return
+	}
+
+	deleted, err := a.ProjectStoreService.DeleteGroup(groupName)
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, DeletedCount{Deleted: deleted})
+}
+
+// groupNameParam reads the {groupName} path param, writing a bad request when
+// it is reserved or holds a character used to build Redis keys.
+func groupNameParam(w http.ResponseWriter, r *http.Request) (string, bool) {
 	groupName := chi.URLParam(r, "groupName")
+	if !projects.IsValidGroupName(groupName) {
+		WriteBadRequest(w, r, "Invalid group name, names may not start with _ or contain : * ? [ ] \\")
+		return "", false
+	}
+
+	return groupName, true
+}
+
+// keyParams reads the {groupName} and {keyName} path params, writing a bad
+// request when either is invalid.
+func keyParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	groupName, ok := groupNameParam(w, r)
+	if !ok {
+		return "", "", false
+	}
+
 	keyName := chi.URLParam(r, "keyName")
+	if !projects.IsValidKeyName(keyName) {
+		WriteBadRequest(w, r, "Invalid key name, names may not contain : * ? [ ] \\")
+		return "", "", false
+	}
 
-	a.ProjectStoreService.SetValue(groupName, keyName, string(bodyBytes))
+	return groupName, keyName, true
+}
+
+func (a *API) GetCollectionItems(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := ParseLimitOffset(r, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		WriteBadRequest(w, r, err.Error())
+		return
+	}
+
+	query := projects.ItemQuery{
+		Sort:   r.URL.Query().Get("sort"),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	itemList, err := a.ProjectStoreService.ListItems(groupName, collection, query)
+	if errors.IsNotValid(err) {
+		WriteBadRequest(w, r, "Invalid sort, use a field name such as createdAt or -createdAt")
+		return
+	}
+
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, itemList)
+}
+
+// AddCollectionItem stores the JSON object body under a new id, answering 201
+// with the stored item.
+func (a *API) AddCollectionItem(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	item, ok := readItem(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := a.ProjectStoreService.AddItem(groupName, collection, item)
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/%s", strings.TrimSuffix(r.URL.Path, "/"), item["id"]))
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	WriteJSON(w, r, item)
+}
+
+func (a *API) GetCollectionItem(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := a.ProjectStoreService.GetItem(groupName, collection, chi.URLParam(r, "itemID"))
+	writeItem(w, r, item, err)
+}
+
+func (a *API) ReplaceCollectionItem(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	item, ok := readItem(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := a.ProjectStoreService.ReplaceItem(groupName, collection, chi.URLParam(r, "itemID"), item)
+	writeItem(w, r, item, err)
+}
+
+// PatchCollectionItem merges the body into the item as a JSON merge patch.
+func (a *API) PatchCollectionItem(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	patch, ok := readItem(w, r)
+	if !ok {
+		return
+	}
+
+	item, err := a.ProjectStoreService.PatchItem(groupName, collection, chi.URLParam(r, "itemID"), patch)
+	writeItem(w, r, item, err)
+}
+
+func (a *API) DeleteCollectionItem(w http.ResponseWriter, r *http.Request) {
+	groupName, collection, ok := collectionParams(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.ProjectStoreService.DeleteItem(groupName, collection, chi.URLParam(r, "itemID"))
+	if errors.IsNotFound(err) {
+		WriteError(w, r, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
 
 	WriteJSON(w, r, OK)
 }
 
+// collectionParams reads the {groupName} and {collectionName} path params,
+// writing a bad request when either is invalid.
+func collectionParams(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	groupName, ok := groupNameParam(w, r)
+	if !ok {
+		return "", "", false
+	}
+
+	collection := chi.URLParam(r, "collectionName")
+	if !projects.IsValidCollectionName(collection) {
+		WriteBadRequest(w, r, "Invalid collection name, use up to 64 letters, digits, _ or -")
+		return "", "", false
+	}
+
+	return groupName, collection, true
+}
+
+// readItem reads a JSON object body of at most projects.MaxItemBytes.
+func readItem(w http.ResponseWriter, r *http.Request) (projects.Item, bool) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, projects.MaxItemBytes+1))
+	if err != nil {
+		WriteBadRequest(w, r, "Error invalid request body.")
+		return nil, false
+	}
+
+	if len(body) > projects.MaxItemBytes {
+		WriteError(w, r, fmt.Sprintf("Items may be at most %d bytes", projects.MaxItemBytes), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	item, err := projects.ParseItem(body)
+	if err != nil {
+		WriteBadRequest(w, r, "Error invalid JSON, items must be JSON objects.")
+		return nil, false
+	}
+
+	return item, true
+}
+
+func writeItem(w http.ResponseWriter, r *http.Request, item projects.Item, err error) {
+	if errors.IsNotFound(err) {
+		WriteError(w, r, "Item not found", http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		WriteServerError(w, r, err)
+		return
+	}
+
+	WriteJSON(w, r, item)
+}
+
 func (a *API) PostFileUpload(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseMultipartForm(50 * 1000 * 1000) // 50 mb
 
@@ -471,8 +1403,9 @@ func (a *API) PostFileUpload(w http.ResponseWriter, r *http.Request) {
 func (a *API) EnableCORS() {
 	corsConfig := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Range", "If-Range", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders:   []string{"Content-Disposition", "Content-Range", "Accept-Ranges", "ETag", "Age", "X-Cache"},
 		AllowCredentials: true,
 		MaxAge:           100, // Maximum value not ignored by any of major browsers
 	})
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/nytimes"
+	"github.com/y3sh/go143/polygon"
+)
+
+const csvContentType = "text/csv; charset=utf-8"
+
+var (
+	barsCSVHeader = []string{"ticker", "time", "open", "high", "low", "close", "volume", "vwap", "transactions"}
+
+	bestSellersCSVHeader = []string{"rank", "lastWeekRank", "weeksOnList", "title", "author", "publisher",
+		"isbn10", "isbn13", "description", "amazonProductUrl", "listName", "publishedDate", "bestsellersDate"}
+)
+
+// WantsCSV reports whether the client asked for CSV with ?format=csv or an
+// Accept header preferring text/csv to JSON. JSON wins ties, so
+// "application/json, text/csv" still gets JSON. Vary: Accept is added when
+// the header decides.
+func WantsCSV(w http.ResponseWriter, r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+
+	csvQuality := acceptQuality(accept, "text", "csv")
+
+	return csvQuality > 0 && csvQuality > acceptQuality(accept, "application", "json")
+}
+
+// acceptQuality returns the q-value the Accept header gives a media type,
+// taken from its most specific matching range.
+func acceptQuality(accept, mainType, subType string) float64 {
+	quality, specificity := 0.0, -1
+
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+
+		entrySpecificity := -1
+
+		switch mediaType {
+		case mainType + "/" + subType:
+			entrySpecificity = 2
+		case mainType + "/*":
+			entrySpecificity = 1
+		case "*/*":
+			entrySpecificity = 0
+		}
+
+		if entrySpecificity <= specificity {
+			continue
+		}
+
+		entryQuality := 1.0
+		if q, ok := params["q"]; ok {
+			entryQuality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		quality, specificity = entryQuality, entrySpecificity
+	}
+
+	return quality
+}
+
+// WriteCSV streams a header row followed by each row as a CSV download named
+// filename.
+func WriteCSV(w http.ResponseWriter, r *http.Request, filename string, header []string, rows func(write func([]string) error) error) {
+	w.Header().Set("content-type", csvContentType)
+	w.Header().Set("content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	csvWriter := csv.NewWriter(w)
+
+	err := csvWriter.Write(header)
+	if err == nil {
+		err = rows(csvWriter.Write)
+	}
+
+	csvWriter.Flush()
+	if err == nil {
+		err = csvWriter.Error()
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"method": r.Method,
+			"url":    r.URL,
+		}).Errorf("Err writing CSV response. \n%+v\n", err)
+
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"method":   r.Method,
+		"url":      r.URL,
+		"filename": filename,
+		"httpCode": http.StatusOK,
+	}).Info("HTTP CSV response sent.")
+}
+
+func WriteBarsCSV(w http.ResponseWriter, r *http.Request, filename string, bars []polygon.TickerBar) {
+	WriteCSV(w, r, filename, barsCSVHeader, func(write func([]string) error) error {
+		for _, bar := range bars {
+			err := write([]string{
+				bar.Ticker,
+				bar.Time,
+				formatFloat(bar.Open),
+				formatFloat(bar.High),
+				formatFloat(bar.Low),
+				formatFloat(bar.Close),
+				formatFloat(bar.Volume),
+				formatFloat(bar.VWAP),
+				strconv.FormatInt(bar.Transactions, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func WriteBestSellersCSV(w http.ResponseWriter, r *http.Request, filename string, books []nytimes.SimpleBook) {
+	WriteCSV(w, r, filename, bestSellersCSVHeader, func(write func([]string) error) error {
+		for _, book := range books {
+			err := write([]string{
+				strconv.FormatInt(book.Rank, 10),
+				strconv.FormatInt(book.LastWeekRank, 10),
+				strconv.FormatInt(book.WeeksOnList, 10),
+				book.Title,
+				book.Author,
+				book.Publisher,
+				book.Isbn10,
+				book.Isbn13,
+				book.Description,
+				book.AmazonProductURL,
+				book.ListName,
+				book.PublishedDate,
+				book.BestsellersDate,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// csvFilename joins parts into a filename safe for Content-Disposition.
+func csvFilename(parts ...string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+
+		return '-'
+	}, strings.Join(parts, "-"))
+
+	return fmt.Sprintf("%s.csv", name)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
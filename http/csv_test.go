@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWantsCSV(t *testing.T) {
+	tests := []struct {
+		url    string
+		accept string
+		want   bool
+		vary   bool
+	}{
+		{"/", "", false, true},
+		{"/", "text/csv", true, true},
+		{"/", "application/json, text/csv", false, true},
+		{"/", "text/csv;q=0.5, application/json;q=0.9", false, true},
+		{"/", "text/csv, application/json;q=0.5", true, true},
+		{"/", "text/csv;q=0", false, true},
+		{"/", "text/*", true, true},
+		{"/", "*/*", false, true},
+		{"/", "*/*;q=0", false, true},
+		{"/", "text/csv, */*;q=0.1", true, true},
+		{"/?format=csv", "application/json", true, false},
+		{"/?format=json", "text/csv", false, false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+
+		w := httptest.NewRecorder()
+
+		if got := WantsCSV(w, r); got != test.want {
+			t.Errorf("WantsCSV(%s, Accept %q) = %v, want %v", test.url, test.accept, got, test.want)
+		}
+
+		if vary := w.Header().Get("Vary") == "Accept"; vary != test.vary {
+			t.Errorf("WantsCSV(%s, Accept %q) set Vary: Accept %v, want %v", test.url, test.accept, vary, test.vary)
+		}
+	}
+}
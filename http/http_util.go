@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	InternalServerErrMessage = "Internal server error"
+	defaultPageLimit         = 20
+	maxPageLimit             = 100
 )
 
 type StatusCode int
@@ -76,3 +80,30 @@ func WriteResponse(w io.Writer, r *http.Request, resBytes []byte) {
 		"httpCode":     http.StatusOK,
 	}).Info("HTTP response sent.")
 }
+
+// ParseLimitOffset reads the limit and offset query params, falling back to
+// defaultLimit and capping the limit at maxLimit.
+func ParseLimitOffset(r *http.Request, defaultLimit, maxLimit int) (limit, offset int, err error) {
+	limit = defaultLimit
+
+	query := r.URL.Query()
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+	}
+
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,430 @@
+// Package httpcache is a shared HTTP cache for upstream clients. It follows
+// the Cache-Control, Expires, ETag and Last-Modified rules for a shared cache,
+// revalidating stale entries with conditional requests.
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/y3sh/go143/upstream"
+)
+
+const (
+	HeaderXCache = "X-Cache"
+	Hit          = "HIT"
+	Miss         = "MISS"
+
+	// Without explicit freshness, a response with Last-Modified is fresh for a
+	// tenth of its age, up to a day.
+	heuristicFraction = 10
+	maxHeuristicAge   = 24 * time.Hour
+)
+
+// cacheableStatuses may be stored, see RFC 9110 section 15.1.
+var cacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// notModifiedSkipped are not copied from a 304 onto the stored response.
+var notModifiedSkipped = map[string]bool{
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+}
+
+// Client caches GET responses from next in store and marks every response
+// with an X-Cache header of HIT or MISS.
+type Client struct {
+	next       upstream.HTTPClient
+	store      *Store
+	defaultTTL func(req *http.Request, res *http.Response) time.Duration
+	now        func() time.Time
+}
+
+func NewClient(next upstream.HTTPClient, store *Store) *Client {
+	return &Client{
+		next:  next,
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// SetDefaultTTL sets how long responses without Cache-Control max-age,
+// s-maxage or Expires stay fresh, for upstreams whose responses are known to
+// be reusable. A zero TTL leaves the response to the usual rules.
+func (c *Client) SetDefaultTTL(defaultTTL func(req *http.Request, res *http.Response) time.Duration) {
+	c.defaultTTL = defaultTTL
+}
+
+func (c *Client) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	key := req.URL.String()
+
+	if !isCacheableRequest(req) {
+		res, err := c.next.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Method != http.MethodGet && req.Method != http.MethodHead && res.StatusCode < http.StatusBadRequest {
+			c.store.remove(key)
+		}
+
+		res.Header.Set(HeaderXCache, Miss)
+
+		return res, nil
+	}
+
+	cached, ok := c.store.get(key)
+	if !ok || !cached.matchesVary(req) {
+		return c.fetch(key, req)
+	}
+
+	if !directives(cached.header).has("no-cache") && c.age(cached) < freshness(cached) {
+		c.store.count(true)
+		return cached.toResponse(req, c.age(cached)), nil
+	}
+
+	etag := cached.header.Get("ETag")
+	lastModified := cached.header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return c.fetch(key, req)
+	}
+
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := c.now()
+
+	res, err := c.next.Do(conditional)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		c.store.count(false)
+		return c.store.record(key, req, res, requestTime, c.now(), c.ttlFor(req, res)), nil
+	}
+
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+
+	revalidated := cached.revalidate(res.Header, requestTime, c.now())
+	c.store.set(revalidated)
+	c.store.count(true)
+
+	return revalidated.toResponse(req, c.age(revalidated)), nil
+}
+
+func (c *Client) fetch(key string, req *http.Request) (*http.Response, error) {
+	requestTime := c.now()
+
+	res, err := c.next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.count(false)
+
+	return c.store.record(key, req, res, requestTime, c.now(), c.ttlFor(req, res)), nil
+}
+
+func (c *Client) ttlFor(req *http.Request, res *http.Response) time.Duration {
+	if c.defaultTTL == nil {
+		return 0
+	}
+
+	return c.defaultTTL(req, res)
+}
+
+// age is the entry's current age, see RFC 9111 section 4.2.3.
+func (c *Client) age(cached *entry) time.Duration {
+	apparentAge := time.Duration(0)
+	if date, err := http.ParseTime(cached.header.Get("Date")); err == nil && cached.responseTime.After(date) {
+		apparentAge = cached.responseTime.Sub(date)
+	}
+
+	correctedAge := cached.responseTime.Sub(cached.requestTime)
+	if ageSeconds, err := strconv.Atoi(cached.header.Get("Age")); err == nil {
+		correctedAge += time.Duration(ageSeconds) * time.Second
+	}
+
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+
+	return correctedAge + c.now().Sub(cached.responseTime)
+}
+
+// record marks res as a miss and, when it may be stored, caches its body once
+// the caller has read it to the end. defaultTTL is the freshness used when res
+// has no explicit freshness of its own.
+func (s *Store) record(key string, req *http.Request, res *http.Response, requestTime, responseTime time.Time,
+	defaultTTL time.Duration) *http.Response {
+	res.Header.Set(HeaderXCache, Miss)
+
+	if !isStorable(res, defaultTTL) {
+		s.remove(key)
+		return res
+	}
+
+	cached := &entry{
+		key:          key,
+		statusCode:   res.StatusCode,
+		header:       res.Header.Clone(),
+		vary:         varyValues(req, res.Header),
+		defaultTTL:   defaultTTL,
+		requestTime:  requestTime,
+		responseTime: responseTime,
+	}
+	cached.header.Del(HeaderXCache)
+
+	res.Body = &recordingBody{
+		body:  res.Body,
+		limit: s.MaxEntryBytes(),
+		onComplete: func(body []byte) {
+			cached.body = body
+			s.set(cached)
+		},
+	}
+
+	return res
+}
+
+func (e *entry) matchesVary(req *http.Request) bool {
+	for name, values := range e.vary {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// revalidate copies e with the headers of a 304 response applied.
+func (e *entry) revalidate(header http.Header, requestTime, responseTime time.Time) *entry {
+	revalidated := *e
+	revalidated.header = e.header.Clone()
+	revalidated.requestTime = requestTime
+	revalidated.responseTime = responseTime
+
+	for name, values := range header {
+		if !notModifiedSkipped[name] && name != HeaderXCache {
+			revalidated.header[name] = values
+		}
+	}
+
+	if header.Get("Date") == "" {
+		revalidated.header.Set("Date", responseTime.UTC().Format(http.TimeFormat))
+	}
+
+	return &revalidated
+}
+
+func (e *entry) toResponse(req *http.Request, age time.Duration) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	header.Set(HeaderXCache, Hit)
+
+	return &http.Response{
+		Status:        strconv.Itoa(e.statusCode) + " " + http.StatusText(e.statusCode),
+		StatusCode:    e.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// freshness is how long the entry may be served without revalidation, see
+// RFC 9111 section 4.2.1.
+func freshness(cached *entry) time.Duration {
+	cacheControl := directives(cached.header)
+
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if cacheControl.has(name) {
+			seconds, err := strconv.Atoi(cacheControl[name])
+			if err != nil || seconds < 0 {
+				return 0
+			}
+
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	date, err := http.ParseTime(cached.header.Get("Date"))
+	if err != nil {
+		date = cached.responseTime
+	}
+
+	if expiresStr := cached.header.Get("Expires"); expiresStr != "" {
+		expires, err := http.ParseTime(expiresStr)
+		if err != nil {
+			return 0
+		}
+
+		return expires.Sub(date)
+	}
+
+	if cached.defaultTTL > 0 {
+		return cached.defaultTTL
+	}
+
+	if lastModified, err := http.ParseTime(cached.header.Get("Last-Modified")); err == nil && date.After(lastModified) {
+		heuristic := date.Sub(lastModified) / heuristicFraction
+		if heuristic > maxHeuristicAge {
+			heuristic = maxHeuristicAge
+		}
+
+		return heuristic
+	}
+
+	return 0
+}
+
+// isCacheableRequest reports whether the cache may answer req. Requests with
+// credentials, ranges or their own validators always go upstream.
+func isCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	for _, name := range []string{"Authorization", "Range", "If-None-Match", "If-Modified-Since", "If-Range"} {
+		if req.Header.Get(name) != "" {
+			return false
+		}
+	}
+
+	cacheControl := directives(req.Header)
+
+	return !cacheControl.has("no-store") && !cacheControl.has("no-cache")
+}
+
+// isStorable reports whether a shared cache may keep res, and whether keeping
+// it could ever save a request.
+func isStorable(res *http.Response, defaultTTL time.Duration) bool {
+	if !cacheableStatuses[res.StatusCode] || res.Header.Get("Vary") == "*" {
+		return false
+	}
+
+	cacheControl := directives(res.Header)
+	if cacheControl.has("no-store") || cacheControl.has("private") {
+		return false
+	}
+
+	for _, name := range []string{"ETag", "Last-Modified", "Expires"} {
+		if res.Header.Get(name) != "" {
+			return true
+		}
+	}
+
+	return defaultTTL > 0 || cacheControl.has("max-age") || cacheControl.has("s-maxage")
+}
+
+func varyValues(req *http.Request, header http.Header) http.Header {
+	vary := http.Header{}
+
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" {
+				vary[name] = req.Header.Values(name)
+			}
+		}
+	}
+
+	return vary
+}
+
+type cacheControl map[string]string
+
+func directives(header http.Header) cacheControl {
+	parsed := cacheControl{}
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg := directive, ""
+			if idx := strings.Index(directive, "="); idx > -1 {
+				name, arg = directive[:idx], strings.Trim(strings.TrimSpace(directive[idx+1:]), `"`)
+			}
+
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				parsed[name] = arg
+			}
+		}
+	}
+
+	return parsed
+}
+
+func (c cacheControl) has(name string) bool {
+	_, ok := c[name]
+	return ok
+}
+
+// recordingBody copies what the caller reads, up to limit bytes, and hands the
+// whole body to onComplete at EOF.
+type recordingBody struct {
+	body       io.ReadCloser
+	buffer     bytes.Buffer
+	limit      int64
+	overflowed bool
+	completed  bool
+	onComplete func(body []byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+
+	if !b.overflowed {
+		if int64(b.buffer.Len()+n) > b.limit {
+			b.overflowed = true
+			b.buffer = bytes.Buffer{}
+		} else {
+			b.buffer.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && !b.overflowed && !b.completed {
+		b.completed = true
+		b.onComplete(b.buffer.Bytes())
+	}
+
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	return b.body.Close()
+}
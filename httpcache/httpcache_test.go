@@ -0,0 +1,257 @@
+package httpcache
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type cacheTest struct {
+	t        *testing.T
+	server   *httptest.Server
+	requests int32
+	client   *Client
+	mutex    sync.Mutex
+	now      time.Time
+}
+
+// newCacheTest serves handler and caches its responses in a store of
+// maxBytes. The server's Date and the cache share a clock the test moves with
+// advance.
+func newCacheTest(t *testing.T, maxBytes int64, handler http.HandlerFunc) *cacheTest {
+	test := &cacheTest{
+		t:   t,
+		now: time.Now(),
+	}
+
+	test.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&test.requests, 1)
+		w.Header().Set("Date", test.clock().UTC().Format(http.TimeFormat))
+		handler(w, r)
+	}))
+	t.Cleanup(test.server.Close)
+
+	test.client = NewClient(test.server.Client(), NewStore(maxBytes))
+	test.client.now = test.clock
+
+	return test
+}
+
+func (c *cacheTest) clock() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *cacheTest) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// get fetches path with header, reading the whole body, and returns the
+// response's X-Cache value and body.
+func (c *cacheTest) get(path string, header http.Header) (string, string) {
+	c.t.Helper()
+
+	req, _ := http.NewRequest(http.MethodGet, c.server.URL+path, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("GET %s returned %v", path, err)
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatalf("reading %s returned %v", path, err)
+	}
+
+	return res.Header.Get(HeaderXCache), string(body)
+}
+
+func (c *cacheTest) expect(path string, header http.Header, xCache string, requests int32) {
+	c.t.Helper()
+
+	if got, _ := c.get(path, header); got != xCache {
+		c.t.Errorf("GET %s was a %s, want %s", path, got, xCache)
+	}
+
+	if got := atomic.LoadInt32(&c.requests); got != requests {
+		c.t.Errorf("upstream saw %d requests, want %d", got, requests)
+	}
+}
+
+func TestMaxAgeHit(t *testing.T) {
+	test := newCacheTest(t, DefaultMaxBytes, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		_, _ = w.Write([]byte("fresh"))
+	})
+
+	test.expect("/a", nil, Miss, 1)
+
+	test.advance(30 * time.Second)
+	if xCache, body := test.get("/a", nil); xCache != Hit || body != "fresh" {
+		t.Errorf("second GET was a %s with %q, want a HIT with %q", xCache, body, "fresh")
+	}
+
+	test.expect("/b", nil, Miss, 2)
+
+	test.advance(time.Minute)
+	test.expect("/a", nil, Miss, 3)
+
+	if stats := test.client.store.Stats(); stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("store counted %d hits and %d misses, want 1 and 3", stats.Hits, stats.Misses)
+	}
+}
+
+func TestStaleRevalidatesWithETag(t *testing.T) {
+	var conditional int32
+
+	test := newCacheTest(t, DefaultMaxBytes, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=10")
+		w.Header().Set("ETag", `"v1"`)
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&conditional, 1)
+			w.WriteHeader(http.StatusNotModified)
+
+			return
+		}
+
+		_, _ = w.Write([]byte("version one"))
+	})
+
+	test.expect("/doc", nil, Miss, 1)
+
+	test.advance(time.Minute)
+	if xCache, body := test.get("/doc", nil); xCache != Hit || body != "version one" {
+		t.Errorf("revalidated GET was a %s with %q", xCache, body)
+	}
+
+	if conditional != 1 {
+		t.Errorf("upstream saw %d conditional requests, want 1", conditional)
+	}
+
+	// The 304 refreshed the entry, so it is fresh again.
+	test.advance(5 * time.Second)
+	test.expect("/doc", nil, Hit, 2)
+}
+
+func TestNoStoreBypass(t *testing.T) {
+	test := newCacheTest(t, DefaultMaxBytes, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secret" {
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		} else {
+			w.Header().Set("Cache-Control", "max-age=60")
+		}
+
+		_, _ = w.Write([]byte("body"))
+	})
+
+	test.expect("/secret", nil, Miss, 1)
+	test.expect("/secret", nil, Miss, 2)
+
+	test.expect("/public", nil, Miss, 3)
+
+	noStore := http.Header{"Cache-Control": {"no-store"}}
+	test.expect("/public", noStore, Miss, 4)
+	test.expect("/public", nil, Hit, 4)
+}
+
+func TestAuthorizationBypass(t *testing.T) {
+	test := newCacheTest(t, DefaultMaxBytes, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("for " + r.Header.Get("Authorization")))
+	})
+
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"Authorization": {"Bearer bob"}}
+
+	test.expect("/me", alice, Miss, 1)
+
+	if xCache, body := test.get("/me", bob); xCache != Miss || body != "for Bearer bob" {
+		t.Errorf("GET as bob was a %s with %q", xCache, body)
+	}
+
+	// Neither authorized response was stored for anonymous requests.
+	test.expect("/me", nil, Miss, 3)
+	test.expect("/me", nil, Hit, 3)
+}
+
+func TestEvictsPastMaxBytes(t *testing.T) {
+	test := newCacheTest(t, 800, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+	})
+
+	for _, path := range []string{"/1", "/2", "/3", "/4", "/5", "/6", "/7", "/8"} {
+		test.get(path, nil)
+	}
+
+	test.expect("/1", nil, Hit, 8)
+
+	// A ninth entry pushes out the least recently used, now /2.
+	test.get("/9", nil)
+	test.expect("/1", nil, Hit, 9)
+	test.expect("/2", nil, Miss, 10)
+
+	stats := test.client.store.Stats()
+	if stats.Size > 800 || stats.Evictions != 2 {
+		t.Errorf("store holds %d bytes after %d evictions, want at most 800 after 2", stats.Size, stats.Evictions)
+	}
+}
+
+func TestSkipsEntriesOverMaxEntryBytes(t *testing.T) {
+	test := newCacheTest(t, 800, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(strings.Repeat("x", 101)))
+	})
+
+	test.expect("/big", nil, Miss, 1)
+	test.expect("/big", nil, Miss, 2)
+}
+
+func TestDefaultTTL(t *testing.T) {
+	test := newCacheTest(t, DefaultMaxBytes, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/explicit":
+			w.Header().Set("Cache-Control", "max-age=5")
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		_, _ = w.Write([]byte("body"))
+	})
+
+	test.client.SetDefaultTTL(func(req *http.Request, res *http.Response) time.Duration {
+		if res.StatusCode != http.StatusOK {
+			return 0
+		}
+
+		return time.Minute
+	})
+
+	test.expect("/plain", nil, Miss, 1)
+	test.expect("/explicit", nil, Miss, 2)
+	test.expect("/private", nil, Miss, 3)
+	test.expect("/private", nil, Miss, 4)
+	test.expect("/missing", nil, Miss, 5)
+	test.expect("/missing", nil, Miss, 6)
+
+	test.advance(30 * time.Second)
+	test.expect("/plain", nil, Hit, 6)
+	test.expect("/explicit", nil, Miss, 7)
+}
@@ -0,0 +1,133 @@
+package httpcache
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/y3sh/go143/cache"
+)
+
+// DefaultMaxBytes bounds the bodies held by a Store.
+const DefaultMaxBytes = 64 << 20
+
+// Store holds cached responses for any number of Clients, evicting the least
+// recently used entries once their bodies exceed maxBytes. Stale entries are
+// kept so they can be revalidated.
+type Store struct {
+	mutex     *sync.Mutex
+	maxBytes  int64
+	usedBytes int64
+	items     map[string]*list.Element
+	order     *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type entry struct {
+	key          string
+	statusCode   int
+	header       http.Header
+	body         []byte
+	vary         http.Header
+	defaultTTL   time.Duration
+	requestTime  time.Time
+	responseTime time.Time
+}
+
+func NewStore(maxBytes int64) *Store {
+	return &Store{
+		mutex:    &sync.Mutex{},
+		maxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// MaxEntryBytes is the largest body worth caching, so one download can't
+// flush the whole store.
+func (s *Store) MaxEntryBytes() int64 {
+	return s.maxBytes / 8
+}
+
+func (s *Store) get(key string) (*entry, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.order.MoveToFront(element)
+
+	return element.Value.(*entry), true
+}
+
+func (s *Store) set(cached *entry) {
+	if int64(len(cached.body)) > s.MaxEntryBytes() {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.items[cached.key]; ok {
+		s.removeElement(element)
+	}
+
+	s.items[cached.key] = s.order.PushFront(cached)
+	s.usedBytes += int64(len(cached.body))
+
+	for s.usedBytes > s.maxBytes && s.order.Len() > 0 {
+		s.removeElement(s.order.Back())
+		s.evictions++
+	}
+}
+
+// count records whether a cacheable request was answered from the store,
+// fresh or revalidated, or needed a full upstream response.
+func (s *Store) count(hit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if hit {
+		s.hits++
+	} else {
+		s.misses++
+	}
+}
+
+func (s *Store) remove(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if element, ok := s.items[key]; ok {
+		s.removeElement(element)
+	}
+}
+
+// Stats reports the store's size and capacity in bytes, and how many
+// cacheable requests it answered.
+func (s *Store) Stats() cache.Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return cache.Stats{
+		Size:      int(s.usedBytes),
+		Capacity:  int(s.maxBytes),
+		Hits:      s.hits,
+		Misses:    s.misses,
+		Evictions: s.evictions,
+	}
+}
+
+func (s *Store) removeElement(element *list.Element) {
+	cached := element.Value.(*entry)
+
+	s.order.Remove(element)
+	delete(s.items, cached.key)
+	s.usedBytes -= int64(len(cached.body))
+}
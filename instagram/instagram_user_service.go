@@ -3,6 +3,7 @@ package instagram
 import (
 	"errors"
 	"math/rand"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -14,6 +15,17 @@ type User struct {
 	Password    string `json:"password"`
 }
 
+// PublicUser is the searchable view of a User without contact or login details.
+type PublicUser struct {
+	FullName string `json:"fullName"`
+	Username string `json:"username"`
+}
+
+type userMatch struct {
+	score int
+	user  User
+}
+
 type RandomUser struct {
 	Name       string   `json:"name"`
 	Location   string   `json:"location"`
@@ -104,6 +116,13 @@ var (
 	}
 )
 
+func (u User) Public() PublicUser {
+	return PublicUser{
+		FullName: u.FullName,
+		Username: u.Username,
+	}
+}
+
 func NewUserService() *UserService {
 	return &UserService{
 		UserMap:   make(map[UserKey]*User),
@@ -206,3 +225,71 @@ func (u *UserService) GetRandProfileByGender(gender string) RandomUser {
 
 	return user
 }
+
+func (u *UserService) SearchUsers(cseName, query string, limit, offset int) []PublicUser {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return []PublicUser{}
+	}
+
+	u.userMutex.Lock()
+	var matches []userMatch
+	for k, user := range u.UserMap {
+		if k.cseName != cseName {
+			continue
+		}
+
+		score := matchScore(query, user)
+		if score > 0 {
+			matches = append(matches, userMatch{score: score, user: *user})
+		}
+	}
+	u.userMutex.Unlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+
+		return strings.ToLower(matches[i].user.Username) < strings.ToLower(matches[j].user.Username)
+	})
+
+	users := []PublicUser{}
+	for i := offset; i < len(matches) && len(users) < limit; i++ {
+		users = append(users, matches[i].user.Public())
+	}
+
+	return users
+}
+
+// matchScore ranks exact usernames first, then prefixes, then substrings,
+// preferring username matches over full name matches at each level.
+func matchScore(query string, user *User) int {
+	username := strings.ToLower(user.Username)
+	fullName := strings.ToLower(user.FullName)
+
+	switch {
+	case username == query:
+		return 6
+	case strings.HasPrefix(username, query):
+		return 5
+	case strings.HasPrefix(fullName, query) || hasWordPrefix(fullName, query):
+		return 4
+	case strings.Contains(username, query):
+		return 3
+	case strings.Contains(fullName, query):
+		return 2
+	}
+
+	return 0
+}
+
+func hasWordPrefix(s, prefix string) bool {
+	for _, word := range strings.Fields(s) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+// Package isbn normalizes and validates ISBN-10 and ISBN-13 book numbers.
+package isbn
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	bookland = "978"
+)
+
+// Normalize strips hyphens and spaces and upper-cases a trailing x.
+func Normalize(isbn string) string {
+	replacer := strings.NewReplacer("-", "", " ", "")
+
+	return strings.ToUpper(replacer.Replace(strings.TrimSpace(isbn)))
+}
+
+func IsValid10(isbn string) bool {
+	isbn = Normalize(isbn)
+	if len(isbn) != 10 {
+		return false
+	}
+
+	sum := 0
+	for i, c := range isbn {
+		var digit int
+
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int(c - '0')
+		case c == 'X' && i == 9:
+			digit = 10
+		default:
+			return false
+		}
+
+		sum += digit * (10 - i)
+	}
+
+	return sum%11 == 0
+}
+
+func IsValid13(isbn string) bool {
+	isbn = Normalize(isbn)
+	if len(isbn) != 13 || !isDigits(isbn) {
+		return false
+	}
+
+	return checkDigit13(isbn[:12]) == isbn[12]
+}
+
+func IsValid(isbn string) bool {
+	return IsValid10(isbn) || IsValid13(isbn)
+}
+
+// To13 returns the ISBN-13 form of a valid ISBN-10 or ISBN-13.
+func To13(isbn string) (string, error) {
+	isbn = Normalize(isbn)
+
+	switch {
+	case IsValid13(isbn):
+		return isbn, nil
+	case IsValid10(isbn):
+		prefix := bookland + isbn[:9]
+
+		return prefix + string(checkDigit13(prefix)), nil
+	}
+
+	return "", errors.NotValidf("isbn %s", isbn)
+}
+
+// To10 returns the ISBN-10 form of a valid ISBN, which only exists for
+// ISBN-13s in the 978 range.
+func To10(isbn string) (string, error) {
+	isbn = Normalize(isbn)
+
+	switch {
+	case IsValid10(isbn):
+		return isbn, nil
+	case IsValid13(isbn):
+		if !strings.HasPrefix(isbn, bookland) {
+			return "", errors.NotSupportedf("isbn-10 for %s", isbn)
+		}
+
+		body := isbn[3:12]
+
+		return body + string(checkDigit10(body)), nil
+	}
+
+	return "", errors.NotValidf("isbn %s", isbn)
+}
+
+// Both returns the ISBN-10 and ISBN-13 forms of isbn. The ISBN-10 is empty
+// for 979 ISBN-13s.
+func Both(isbn string) (isbn10, isbn13 string, err error) {
+	isbn13, err = To13(isbn)
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+
+	isbn10, _ = To10(isbn13)
+
+	return isbn10, isbn13, nil
+}
+
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+
+	return byte('0' + check)
+}
+
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+
+		sum += int(body[i]-'0') * weight
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
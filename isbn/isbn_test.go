@@ -0,0 +1,72 @@
+package isbn
+
+import "testing"
+
+func TestIsValid(t *testing.T) {
+	tests := []struct {
+		isbn    string
+		valid10 bool
+		valid13 bool
+	}{
+		{"0306406152", true, false},
+		{"0-306-40615-2", true, false},
+		{"080442957X", true, false},
+		{"080442957x", true, false},
+		{"0804429570", false, false},
+		{"X804429570", false, false},
+		{"9780306406157", false, true},
+		{"978-0-306-40615-7", false, true},
+		{"9780306406158", false, false},
+		{"9791090636071", false, true},
+		{"97803064061", false, false},
+		{"", false, false},
+	}
+
+	for _, test := range tests {
+		if got := IsValid10(test.isbn); got != test.valid10 {
+			t.Errorf("IsValid10(%q) = %v, want %v", test.isbn, got, test.valid10)
+		}
+
+		if got := IsValid13(test.isbn); got != test.valid13 {
+			t.Errorf("IsValid13(%q) = %v, want %v", test.isbn, got, test.valid13)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		isbn   string
+		isbn10 string
+		isbn13 string
+	}{
+		{"0306406152", "0306406152", "9780306406157"},
+		{"9780306406157", "0306406152", "9780306406157"},
+		{"080442957X", "080442957X", "9780804429573"},
+		{"9780804429573", "080442957X", "9780804429573"},
+		{"9791090636071", "", "9791090636071"},
+	}
+
+	for _, test := range tests {
+		isbn13, err := To13(test.isbn)
+		if err != nil || isbn13 != test.isbn13 {
+			t.Errorf("To13(%q) = %q, %v, want %q", test.isbn, isbn13, err, test.isbn13)
+		}
+
+		isbn10, err := To10(test.isbn)
+		if test.isbn10 == "" {
+			if err == nil {
+				t.Errorf("To10(%q) = %q, want an error", test.isbn, isbn10)
+			}
+
+			continue
+		}
+
+		if err != nil || isbn10 != test.isbn10 {
+			t.Errorf("To10(%q) = %q, %v, want %q", test.isbn, isbn10, err, test.isbn10)
+		}
+	}
+
+	if _, err := To13("0306406153"); err == nil {
+		t.Errorf("To13 accepted an invalid ISBN-10")
+	}
+}
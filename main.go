@@ -1,20 +1,22 @@
 package main
 
 import (
-	"crypto/tls"
 	"flag"
 	"fmt"
-	"net"
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/fixtures"
+	"github.com/y3sh/go143/googlebooks"
 	go143http "github.com/y3sh/go143/http"
+	"github.com/y3sh/go143/httpcache"
 	"github.com/y3sh/go143/instagram"
 	"github.com/y3sh/go143/nytimes"
 	"github.com/y3sh/go143/polygon"
@@ -22,14 +24,16 @@ import (
 	"github.com/y3sh/go143/proxyURL"
 	"github.com/y3sh/go143/repository"
 	"github.com/y3sh/go143/twitter"
+	"github.com/y3sh/go143/upstream"
 )
 
 const (
-	dialTimeout       = 5 * time.Second
-	handshakeTimeout  = 5 * time.Second
-	responseTimeout   = time.Second * 10
 	DebugTSFormat     = "2006-01-02 03:04:05PM MST"
 	longestFileLength = 28
+	defaultFixtureDir = "fixtures/data"
+
+	polygonProviderLive      = "live"
+	polygonProviderSimulated = "simulated"
 )
 
 func main() {
@@ -74,15 +78,48 @@ func main() {
 
 	tweetService := twitter.NewTweetService()
 	instagramUserService := instagram.NewUserService()
-	nyTimesClient := nytimes.NewRestClient(nyTimesAPIKey, googleBooksAPIKey, GetHTTPClient())
-	polygonClient := polygon.NewRestClient(polygonAPIKey, GetHTTPClient())
-	proxyClient := proxyURL.NewProxyClient(GetHTTPClient())
+	upstreamBreakers := upstream.NewBreakers()
+	nyTimesClient := nytimes.NewRestClient(nyTimesAPIKey, googleBooksAPIKey, GetUpstreamClient("NY_TIMES", upstreamBreakers))
+	nyTimesClient.SetBestSellersTTL(
+		getEnvDuration("NY_TIMES_CACHE_TTL", nytimes.DefaultBestSellersTTL),
+		getEnvDuration("NY_TIMES_CACHE_STALE_TTL", nytimes.DefaultBestSellersStale))
+	if getEnv("NY_TIMES_CACHE_PERSIST", "true") == "true" {
+		nyTimesClient.PersistBestSellersTo(redisRepository)
+	}
+
+	switch coverStorage := getEnv("COVER_STORAGE", "s3"); coverStorage {
+	case "s3":
+		nyTimesClient.StoreCoversIn(s3Repository)
+	case "local":
+		localRepository, err := repository.NewLocalRepository(getEnv("COVER_STORAGE_DIR", "covers"))
+		if err != nil {
+			log.Fatalf("Failed to create cover storage. \n%+v\n", err)
+		}
+
+		nyTimesClient.StoreCoversIn(localRepository)
+	default:
+		log.Warnf("Unknown COVER_STORAGE %s, covers will not be stored", coverStorage)
+	}
+
+	googleBooksClient := googlebooks.NewRestClient(googleBooksAPIKey, GetUpstreamClient("GOOGLE_BOOKS", upstreamBreakers))
+	httpCache := httpcache.NewStore(int64(getEnvInt("HTTP_CACHE_MAX_BYTES", httpcache.DefaultMaxBytes)))
+	polygonClient := GetPolygonClient(polygonAPIKey, httpCache, upstreamBreakers)
+	stockService := polygon.NewStockService(polygonClient)
+	// Students proxy to arbitrary hosts, so the proxy has no breakers to keep
+	// the breaker set bounded.
+	proxyGuard := proxyURL.NewURLGuard(strings.Split(getEnv("PROXY_ALLOWED_DOMAINS", ""), ","))
+	proxyUpstream := GetUpstreamClientFor(proxyGuard.Configure(GetUpstreamConfig("PROXY", upstream.DefaultConfig("PROXY"))))
+	proxyClient := proxyURL.NewProxyClient(httpcache.NewClient(proxyUpstream, httpCache), proxyGuard)
+	proxyClient.SetMaxResponseBytes(int64(getEnvInt("PROXY_MAX_RESPONSE_BYTES", proxyURL.DefaultMaxResponseBytes)))
+	proxyClient.SetMaxRequestBytes(int64(getEnvInt("PROXY_MAX_REQUEST_BYTES", proxyURL.DefaultMaxRequestBytes)))
+	proxyClient.SetMethods(strings.Split(getEnv("PROXY_METHODS", strings.Join(proxyURL.DefaultMethods, ",")), ","))
 	projectService := projects.NewProjectStoreService(redisRepository)
 
 	chiRouter := chi.NewRouter()
 
 	go143http.NewAPIRouter(chiRouter, tweetService, instagramUserService,
-		nyTimesClient, polygonClient, proxyClient, projectService, s3Repository)
+		nyTimesClient, googleBooksClient, polygonClient, stockService, proxyClient, projectService, s3Repository,
+		upstreamBreakers, httpCache)
 
 	log.Infof("REST API starting on %s . . .", hostAddress)
 	err = http.ListenAndServe(hostAddress, chiRouter)
@@ -99,6 +136,36 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warnf("Invalid duration %s=%s, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		log.Warnf("Invalid integer %s=%s, using %d", key, value, fallback)
+		return fallback
+	}
+
+	return intValue
+}
+
 func SetupLogger(logLevelStr string) {
 	if logLevelStr == "" {
 		logLevelStr = "trace"
@@ -142,18 +209,106 @@ func SetupLogger(logLevelStr string) {
 	log.Infof("Logger started with %s level.", log.GetLevel())
 }
 
-func GetHTTPClient() *http.Client {
-	httpTransport := &http.Transport{
-		Dial: (&net.Dialer{
-			Timeout: dialTimeout,
-		}).Dial,
-		TLSHandshakeTimeout: handshakeTimeout,
-		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+// GetUpstreamConfig reads the HTTP client settings for one integration from
+// {envPrefix}_UPSTREAM_* variables, falling back to UPSTREAM_* and then to
+// defaults.
+func GetUpstreamConfig(envPrefix string, defaults upstream.Config) upstream.Config {
+	config := defaults
+
+	config.Timeout = getUpstreamEnvDuration(envPrefix, "TIMEOUT", config.Timeout)
+	config.DialTimeout = getUpstreamEnvDuration(envPrefix, "DIAL_TIMEOUT", config.DialTimeout)
+	config.MaxRetries = getEnvInt(envPrefix+"_UPSTREAM_RETRIES", getEnvInt("UPSTREAM_RETRIES", config.MaxRetries))
+	config.RetryBaseDelay = getUpstreamEnvDuration(envPrefix, "RETRY_BASE_DELAY", config.RetryBaseDelay)
+	config.RetryMaxDelay = getUpstreamEnvDuration(envPrefix, "RETRY_MAX_DELAY", config.RetryMaxDelay)
+	config.BreakerThreshold = getEnvInt(envPrefix+"_UPSTREAM_BREAKER_THRESHOLD",
+		getEnvInt("UPSTREAM_BREAKER_THRESHOLD", config.BreakerThreshold))
+	config.BreakerCooldown = getUpstreamEnvDuration(envPrefix, "BREAKER_COOLDOWN", config.BreakerCooldown)
+	config.CABundle = getEnv(envPrefix+"_UPSTREAM_CA_BUNDLE", getEnv("UPSTREAM_CA_BUNDLE", config.CABundle))
+	config.ProxyURL = getEnv(envPrefix+"_UPSTREAM_PROXY", getEnv("UPSTREAM_PROXY", config.ProxyURL))
+	config.InsecureSkipVerify = getEnv(envPrefix+"_UPSTREAM_INSECURE_SKIP_VERIFY",
+		getEnv("UPSTREAM_INSECURE_SKIP_VERIFY", "false")) == "true"
+
+	return config
+}
+
+func getUpstreamEnvDuration(envPrefix, name string, fallback time.Duration) time.Duration {
+	return getEnvDuration(envPrefix+"_UPSTREAM_"+name, getEnvDuration("UPSTREAM_"+name, fallback))
+}
+
+// GetUpstreamClient returns the HTTP client for one integration configured
+// from the environment, reporting its hosts' state to breakers.
+func GetUpstreamClient(envPrefix string, breakers *upstream.Breakers) upstream.HTTPClient {
+	defaults := upstream.DefaultConfig(envPrefix)
+	defaults.Breakers = breakers
+
+	return GetUpstreamClientFor(GetUpstreamConfig(envPrefix, defaults))
+}
+
+// GetUpstreamClientFor builds the client for config, wrapped for fixture
+// replay or recording when {config.Name}_UPSTREAM_MODE or UPSTREAM_MODE is
+// fixture or record.
+func GetUpstreamClientFor(config upstream.Config) upstream.HTTPClient {
+	mode := GetUpstreamMode(config.Name)
+
+	httpClient, err := upstream.NewClient(config)
+	if err != nil {
+		log.Fatalf("Failed to create %s upstream client. \n%+v\n", config.Name, err)
+	}
+
+	if mode == fixtures.ModeLive {
+		return httpClient
 	}
-	httpClient := &http.Client{
-		Timeout:   responseTimeout,
-		Transport: httpTransport,
+
+	log.Infof("%s upstream running in %s mode", config.Name, mode)
+
+	return fixtures.NewClient(mode, httpClient, getEnv("FIXTURE_DIR", defaultFixtureDir))
+}
+
+// GetUpstreamMode returns the integration's upstream mode, which defaults to
+// UPSTREAM_MODE.
+func GetUpstreamMode(envPrefix string) string {
+	mode := getEnv(envPrefix+"_UPSTREAM_MODE", getEnv("UPSTREAM_MODE", fixtures.ModeLive))
+	if !fixtures.IsValidMode(mode) {
+		log.Fatalf("Invalid upstream mode %s for %s, expected live, fixture or record", mode, envPrefix)
+	}
+
+	return mode
+}
+
+// GetPolygonClient returns the live Polygon client, or the simulated market
+// when POLYGON_PROVIDER is simulated or no API key is configured.
+func GetPolygonClient(polygonAPIKey string, httpCache *httpcache.Store, breakers *upstream.Breakers) go143http.PolygonClient {
+	defaultProvider := polygonProviderLive
+	if polygonAPIKey == "" {
+		defaultProvider = polygonProviderSimulated
+	}
+
+	switch provider := getEnv("POLYGON_PROVIDER", defaultProvider); provider {
+	case polygonProviderLive:
+		// Every attempt counts against the Polygon plan, so only retry when asked.
+		upstreamConfig := upstream.DefaultConfig("POLYGON")
+		upstreamConfig.MaxRetries = 0
+		upstreamConfig.Breakers = breakers
+
+		upstreamClient := GetUpstreamClientFor(GetUpstreamConfig("POLYGON", upstreamConfig))
+		polygonClient := polygon.NewRestClient(polygonAPIKey, upstreamClient, httpCache)
+		polygonClient.SetRateLimit(
+			getEnvInt("POLYGON_REQUESTS_PER_MINUTE", polygon.DefaultRequestsPerMinute),
+			getEnvInt("POLYGON_BURST", polygon.DefaultBurst))
+
+		// Replayed fixtures cost nothing, so only live and record modes are limited.
+		if GetUpstreamMode("POLYGON") == fixtures.ModeFixture {
+			polygonClient.DisableRateLimit()
+		}
+
+		return polygonClient
+	case polygonProviderSimulated:
+		log.Infof("Polygon running against simulated market data")
+
+		return polygon.NewSimulatedClient()
+	default:
+		log.Fatalf("Invalid POLYGON_PROVIDER %s, expected live or simulated", provider)
 	}
 
-	return httpClient
+	return nil
 }
@@ -0,0 +1,248 @@
+package nytimes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/cache"
+)
+
+const (
+	DefaultBestSellersTTL   = 6 * time.Hour
+	DefaultBestSellersStale = 24 * time.Hour
+	historicalSnapshotTTL   = 30 * 24 * time.Hour
+	snapshotKeyPrefix       = "_nytimes:bestSellers"
+	// maxSnapshots bounds the lists held in memory, since every ?date= asked
+	// for adds one.
+	maxSnapshots = 512
+)
+
+type snapshotStore interface {
+	SetKeyValueTTL(key, value string, ttl time.Duration) error
+	GetValue(key string) (string, error)
+	GetValues(keys []string) ([]string, error)
+	ScanKeys(pattern string) ([]string, error)
+}
+
+type listSnapshot struct {
+	FetchedAt   time.Time     `json:"fetchedAt"`
+	BestSellers BestSellerRes `json:"bestSellers"`
+}
+
+// bestSellerCache holds one snapshot per list and date. Fresh snapshots are
+// served as is, stale ones are served while a refresh runs in the background,
+// and concurrent misses for the same list share a single upstream request.
+type bestSellerCache struct {
+	mutex     *sync.RWMutex
+	snapshots map[string]listSnapshot
+	ttl       time.Duration
+	staleTTL  time.Duration
+	flights   *cache.Group
+	store     snapshotStore
+	load      func(listName, date string) (BestSellerRes, error)
+}
+
+func newBestSellerCache(load func(listName, date string) (BestSellerRes, error)) *bestSellerCache {
+	return &bestSellerCache{
+		mutex:     &sync.RWMutex{},
+		snapshots: make(map[string]listSnapshot),
+		ttl:       DefaultBestSellersTTL,
+		staleTTL:  DefaultBestSellersStale,
+		flights:   cache.NewGroup(),
+		load:      load,
+	}
+}
+
+func (c *bestSellerCache) Get(listName, date string) BestSellerRes {
+	key := snapshotKey(listName, date)
+
+	snapshot, ok := c.lookup(key)
+	if ok {
+		age := time.Since(snapshot.FetchedAt)
+		if age < c.ttlFor(date) {
+			return snapshot.BestSellers
+		}
+
+		if age < c.ttlFor(date)+c.staleTTL {
+			go c.refresh(key, listName, date)
+
+			return snapshot.BestSellers
+		}
+	}
+
+	fetched, err := c.refresh(key, listName, date)
+	if err != nil {
+		log.Errorf("Could not fetch best sellers %s, %s", key, err.Error())
+
+		return snapshot.BestSellers
+	}
+
+	return fetched
+}
+
+// Snapshots returns every list held in memory or in the store. Stored lists
+// are not kept in memory, so reading history doesn't evict the lists being
+// served.
+func (c *bestSellerCache) Snapshots() []BestSellerRes {
+	c.mutex.RLock()
+	snapshots := make([]BestSellerRes, 0, len(c.snapshots))
+	held := make(map[string]bool, len(c.snapshots))
+	for key, snapshot := range c.snapshots {
+		snapshots = append(snapshots, snapshot.BestSellers)
+		held[key] = true
+	}
+	c.mutex.RUnlock()
+
+	return append(snapshots, c.storedSnapshots(held)...)
+}
+
+// storedSnapshots loads the persisted lists whose keys are not in held.
+func (c *bestSellerCache) storedSnapshots(held map[string]bool) []BestSellerRes {
+	if c.store == nil {
+		return nil
+	}
+
+	keys, err := c.store.ScanKeys(snapshotKeyPrefix + ":*")
+	if err != nil {
+		log.Errorf("Could not list best seller snapshots, %s", err.Error())
+		return nil
+	}
+
+	missing := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !held[key] {
+			missing = append(missing, key)
+		}
+	}
+
+	storedJSON, err := c.store.GetValues(missing)
+	if err != nil {
+		log.Errorf("Could not load best seller snapshots, %s", err.Error())
+		return nil
+	}
+
+	snapshots := make([]BestSellerRes, 0, len(storedJSON))
+	for i, value := range storedJSON {
+		if value == "" {
+			continue
+		}
+
+		var snapshot listSnapshot
+		if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
+			log.Warnf("Ignoring unreadable best seller snapshot %s, %s", missing[i], err.Error())
+			continue
+		}
+
+		snapshots = append(snapshots, snapshot.BestSellers)
+	}
+
+	return snapshots
+}
+
+func (c *bestSellerCache) lookup(key string) (listSnapshot, bool) {
+	c.mutex.RLock()
+	snapshot, ok := c.snapshots[key]
+	c.mutex.RUnlock()
+
+	if ok || c.store == nil {
+		return snapshot, ok
+	}
+
+	storedJSON, err := c.store.GetValue(key)
+	if err != nil || storedJSON == "" {
+		return snapshot, false
+	}
+
+	err = json.Unmarshal([]byte(storedJSON), &snapshot)
+	if err != nil {
+		log.Warnf("Ignoring unreadable best seller snapshot %s, %s", key, err.Error())
+		return listSnapshot{}, false
+	}
+
+	c.remember(key, snapshot)
+
+	return snapshot, true
+}
+
+func (c *bestSellerCache) refresh(key, listName, date string) (BestSellerRes, error) {
+	fetched, _, err := c.flights.Do(key, func() (interface{}, error) {
+		bestSellers, err := c.load(listName, date)
+		if err != nil {
+			return nil, err
+		}
+
+		snapshot := listSnapshot{
+			FetchedAt:   time.Now(),
+			BestSellers: bestSellers,
+		}
+
+		c.remember(key, snapshot)
+
+		c.persist(key, date, snapshot)
+
+		return bestSellers, nil
+	})
+	if err != nil {
+		return BestSellerRes{}, err
+	}
+
+	return fetched.(BestSellerRes), nil
+}
+
+// remember holds snapshot in memory, dropping the least recently fetched one
+// when maxSnapshots are already held. Dropped lists are reloaded from the store.
+func (c *bestSellerCache) remember(key string, snapshot listSnapshot) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.snapshots[key]; !ok && len(c.snapshots) >= maxSnapshots {
+		oldestKey := ""
+
+		for heldKey, held := range c.snapshots {
+			if oldestKey == "" || held.FetchedAt.Before(c.snapshots[oldestKey].FetchedAt) {
+				oldestKey = heldKey
+			}
+		}
+
+		delete(c.snapshots, oldestKey)
+	}
+
+	c.snapshots[key] = snapshot
+}
+
+func (c *bestSellerCache) persist(key, date string, snapshot listSnapshot) {
+	if c.store == nil {
+		return
+	}
+
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		log.Errorf("Could not marshal best seller snapshot %s, %s", key, err.Error())
+		return
+	}
+
+	err = c.store.SetKeyValueTTL(key, string(snapshotJSON), c.ttlFor(date)+c.staleTTL)
+	if err != nil {
+		log.Errorf("Could not persist best seller snapshot %s, %s", key, err.Error())
+	}
+}
+
+// ttlFor keeps dated lists much longer since a published list never changes.
+func (c *bestSellerCache) ttlFor(date string) time.Duration {
+	if date != "" {
+		return historicalSnapshotTTL
+	}
+
+	return c.ttl
+}
+
+func snapshotKey(listName, date string) string {
+	if date == "" {
+		date = "current"
+	}
+
+	return fmt.Sprintf("%s:%s:%s", snapshotKeyPrefix, listName, date)
+}
@@ -0,0 +1,308 @@
+package nytimes
+
+import (
+	"bytes"
+	_ "embed" // embed the placeholder cover
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register gif decoding for covers
+	"image/jpeg"
+	_ "image/png" // register png decoding for covers
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxCoverBytes    = 5 * 1000 * 1000 // 5 mb
+	coverJPEGQuality = 85
+	coverKeyPrefix   = "covers"
+)
+
+// placeholderPNG is served for books without a cover, so it needs neither
+// Google nor the network.
+//
+//go:embed bookPlaceholder.png
+var placeholderPNG []byte
+
+// CoverWidths are the widths a cover may be resized to, so storage holds at
+// most a handful of renditions per book.
+var CoverWidths = []int{64, 128, 256, 512}
+
+type CoverImage struct {
+	ContentType string
+	Data        []byte
+	// placeholder is set when no cover was found, or Google Books failed, so
+	// the placeholder image is served without being stored for the book.
+	placeholder bool
+}
+
+type objectStore interface {
+	PutObject(key, contentType string, data []byte) error
+	GetObject(key string) ([]byte, string, error)
+}
+
+// StoreCoversIn keeps fetched cover images in store so each is only
+// downloaded from Google once.
+func (r *RestClient) StoreCoversIn(store objectStore) {
+	r.coverStore = store
+}
+
+// IsPlaceholder reports whether the placeholder was served in place of the
+// book's cover, so it should only be cached briefly.
+func (c CoverImage) IsPlaceholder() bool {
+	return c.placeholder
+}
+
+func IsValidCoverWidth(width int) bool {
+	if width == 0 {
+		return true
+	}
+
+	for _, allowed := range CoverWidths {
+		if width == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetBookCoverImage returns the cover bytes for isbn, resized to width when
+// width is not zero.
+func (r *RestClient) GetBookCoverImage(isbn string, width int) (CoverImage, error) {
+	if !IsValidCoverWidth(width) {
+		return CoverImage{}, errors.NotValidf("cover width %d", width)
+	}
+
+	key := coverObjectKey(isbn, width)
+	if cover, ok := r.loadCover(key); ok {
+		return cover, nil
+	}
+
+	coverIf, _, err := r.coverFlights.Do(key, func() (interface{}, error) {
+		return r.fetchCover(isbn, width)
+	})
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+
+	return coverIf.(CoverImage), nil
+}
+
+func (r *RestClient) fetchCover(isbn string, width int) (CoverImage, error) {
+	original, err := r.getOriginalCover(isbn)
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+
+	if width == 0 {
+		return original, nil
+	}
+
+	if original.placeholder {
+		if resized, ok := r.placeholderAt(width); ok {
+			return resized, nil
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(original.Data))
+	if err != nil {
+		return CoverImage{}, errors.Annotatef(err, "could not decode cover for %s", isbn)
+	}
+
+	var buf bytes.Buffer
+
+	err = jpeg.Encode(&buf, resizeImage(img, width), &jpeg.Options{Quality: coverJPEGQuality})
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+
+	resized := CoverImage{
+		ContentType: "image/jpeg",
+		Data:        buf.Bytes(),
+		placeholder: original.placeholder,
+	}
+	if resized.placeholder {
+		r.rememberPlaceholder(width, resized)
+	} else {
+		r.saveCover(coverObjectKey(isbn, width), resized)
+	}
+
+	return resized, nil
+}
+
+func (r *RestClient) getOriginalCover(isbn string) (CoverImage, error) {
+	key := coverObjectKey(isbn, 0)
+	if cover, ok := r.loadCover(key); ok {
+		return cover, nil
+	}
+
+	coverURL := r.GetBookCoverURL(isbn).URL
+	if coverURL == BookPlaceholderURL {
+		return CoverImage{ContentType: "image/png", Data: placeholderPNG, placeholder: true}, nil
+	}
+
+	coverURL = toHTTPS(coverURL)
+
+	req, err := http.NewRequest(http.MethodGet, coverURL, nil)
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return CoverImage{}, errors.Errorf("non 200 status %d fetching cover %s", res.StatusCode, coverURL)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxCoverBytes+1))
+	if err != nil {
+		return CoverImage{}, errors.Trace(err)
+	}
+
+	if len(data) > maxCoverBytes {
+		return CoverImage{}, errors.Errorf("cover %s is larger than %d bytes", coverURL, maxCoverBytes)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return CoverImage{}, errors.Errorf("cover %s is %s, not an image", coverURL, contentType)
+	}
+
+	cover := CoverImage{
+		ContentType: contentType,
+		Data:        data,
+	}
+	r.saveCover(key, cover)
+
+	return cover, nil
+}
+
+// placeholderAt returns the placeholder already resized to width, if any.
+func (r *RestClient) placeholderAt(width int) (CoverImage, bool) {
+	r.placeholderMutex.Lock()
+	defer r.placeholderMutex.Unlock()
+
+	resized, ok := r.placeholders[width]
+
+	return resized, ok
+}
+
+// rememberPlaceholder keeps the resized placeholder in memory rather than in
+// storage, where it would be mistaken for a book's cover.
+func (r *RestClient) rememberPlaceholder(width int, resized CoverImage) {
+	r.placeholderMutex.Lock()
+	defer r.placeholderMutex.Unlock()
+
+	r.placeholders[width] = resized
+}
+
+func (r *RestClient) loadCover(key string) (CoverImage, bool) {
+	if r.coverStore == nil {
+		return CoverImage{}, false
+	}
+
+	data, contentType, err := r.coverStore.GetObject(key)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Errorf("Could not read stored cover %s, %s", key, err.Error())
+		}
+
+		return CoverImage{}, false
+	}
+
+	return CoverImage{ContentType: contentType, Data: data}, true
+}
+
+func (r *RestClient) saveCover(key string, cover CoverImage) {
+	if r.coverStore == nil {
+		return
+	}
+
+	err := r.coverStore.PutObject(key, cover.ContentType, cover.Data)
+	if err != nil {
+		log.Errorf("Could not store cover %s, %s", key, err.Error())
+	}
+}
+
+func coverObjectKey(isbn string, width int) string {
+	if width == 0 {
+		return fmt.Sprintf("%s/%s/original", coverKeyPrefix, isbn)
+	}
+
+	return fmt.Sprintf("%s/%s/w%d.jpg", coverKeyPrefix, isbn, width)
+}
+
+// toHTTPS upgrades Google's http thumbnail links, which are also served over
+// https, to avoid mixed-content warnings.
+func toHTTPS(coverURL string) string {
+	if strings.HasPrefix(coverURL, "http://") {
+		return "https://" + strings.TrimPrefix(coverURL, "http://")
+	}
+
+	return coverURL
+}
+
+// resizeImage scales img to width with bilinear sampling, keeping the aspect
+// ratio. Images already narrower than width are only re-encoded.
+func resizeImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= width || srcW == 0 {
+		return img
+	}
+
+	height := srcH * width / srcW
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	xRatio := float64(srcW-1) / float64(width)
+	yRatio := float64(srcH-1) / float64(height)
+
+	for y := 0; y < height; y++ {
+		srcY := float64(y) * yRatio
+		y0 := int(srcY)
+		yFrac := srcY - float64(y0)
+
+		for x := 0; x < width; x++ {
+			srcX := float64(x) * xRatio
+			x0 := int(srcX)
+			xFrac := srcX - float64(x0)
+
+			top := lerpColor(img.At(bounds.Min.X+x0, bounds.Min.Y+y0), img.At(bounds.Min.X+x0+1, bounds.Min.Y+y0), xFrac)
+			bottom := lerpColor(img.At(bounds.Min.X+x0, bounds.Min.Y+y0+1), img.At(bounds.Min.X+x0+1, bounds.Min.Y+y0+1), xFrac)
+			dst.Set(x, y, lerpRGBA(top, bottom, yFrac))
+		}
+	}
+
+	return dst
+}
+
+func lerpColor(a, b color.Color, t float64) color.RGBA {
+	return lerpRGBA(color.RGBAModel.Convert(a).(color.RGBA), color.RGBAModel.Convert(b).(color.RGBA), t)
+}
+
+func lerpRGBA(a, b color.RGBA, t float64) color.RGBA {
+	lerp := func(from, to uint8) uint8 {
+		return uint8(float64(from) + (float64(to)-float64(from))*t + 0.5)
+	}
+
+	return color.RGBA{
+		R: lerp(a.R, b.R),
+		G: lerp(a.G, b.G),
+		B: lerp(a.B, b.B),
+		A: lerp(a.A, b.A),
+	}
+}
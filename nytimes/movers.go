@@ -0,0 +1,107 @@
+package nytimes
+
+import (
+	"sort"
+)
+
+const (
+	DefaultMoversLimit = 5
+)
+
+// Mover is a book with the number of places it moved since last week,
+// positive when it climbed.
+type Mover struct {
+	SimpleBook
+	Change int64 `json:"change"`
+}
+
+type Movers struct {
+	// ListName is the encoded name the list was asked for, such as
+	// hardcover-fiction, and DisplayName the name NYT shows.
+	ListName       string  `json:"listName"`
+	DisplayName    string  `json:"displayName"`
+	PublishedDate  string  `json:"publishedDate"`
+	NewEntries     []Mover `json:"newEntries"`
+	Climbers       []Mover `json:"climbers"`
+	Fallers        []Mover `json:"fallers"`
+	LongestRunning []Mover `json:"longestRunning"`
+}
+
+// GetMovers summarizes how a list changed from the week before, keeping at
+// most limit books in each category.
+func (r *RestClient) GetMovers(listName, date string, limit int) Movers {
+	movers := Movers{
+		ListName:       listName,
+		NewEntries:     []Mover{},
+		Climbers:       []Mover{},
+		Fallers:        []Mover{},
+		LongestRunning: []Mover{},
+	}
+
+	books := r.GetSimpleBestSellers(listName, date)
+	for _, book := range books {
+		movers.DisplayName = book.ListName
+		movers.PublishedDate = book.PublishedDate
+
+		mover := Mover{SimpleBook: book}
+
+		// NYT reports a last week rank of 0 for books that were not on the list.
+		if book.LastWeekRank == 0 {
+			movers.NewEntries = append(movers.NewEntries, mover)
+		} else {
+			mover.Change = book.LastWeekRank - book.Rank
+		}
+
+		switch {
+		case mover.Change > 0:
+			movers.Climbers = append(movers.Climbers, mover)
+		case mover.Change < 0:
+			movers.Fallers = append(movers.Fallers, mover)
+		}
+
+		movers.LongestRunning = append(movers.LongestRunning, mover)
+	}
+
+	sortMovers(movers.NewEntries, func(a, b Mover) bool {
+		return a.Rank < b.Rank
+	})
+	sortMovers(movers.Climbers, func(a, b Mover) bool {
+		return a.Change > b.Change
+	})
+	sortMovers(movers.Fallers, func(a, b Mover) bool {
+		return a.Change < b.Change
+	})
+	sortMovers(movers.LongestRunning, func(a, b Mover) bool {
+		return a.WeeksOnList > b.WeeksOnList
+	})
+
+	movers.NewEntries = limitMovers(movers.NewEntries, limit)
+	movers.Climbers = limitMovers(movers.Climbers, limit)
+	movers.Fallers = limitMovers(movers.Fallers, limit)
+	movers.LongestRunning = limitMovers(movers.LongestRunning, limit)
+
+	return movers
+}
+
+// sortMovers orders by less, breaking ties by current rank.
+func sortMovers(movers []Mover, less func(a, b Mover) bool) {
+	sort.SliceStable(movers, func(i, j int) bool {
+		if less(movers[i], movers[j]) {
+			return true
+		}
+
+		if less(movers[j], movers[i]) {
+			return false
+		}
+
+		return movers[i].Rank < movers[j].Rank
+	})
+}
+
+func limitMovers(movers []Mover, limit int) []Mover {
+	if len(movers) > limit {
+		return movers[:limit]
+	}
+
+	return movers
+}
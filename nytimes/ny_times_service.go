@@ -5,12 +5,37 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/cache"
+	"github.com/y3sh/go143/isbn"
+	"github.com/y3sh/go143/upstream"
 )
 
+const (
+	DefaultListName = "hardcover-fiction"
+	bestSellersURL  = "https://api.nytimes.com/svc/books/v3/lists.json?list-name=%s&api-key=%s"
+	publishedDateQS = "&published-date=%s"
+	DateFormat      = "2006-01-02"
+	listNamesURL    = "https://api.nytimes.com/svc/books/v3/lists/names.json?api-key=%s"
+	listNamesMaxAge = 12 * time.Hour
+
+	BookPlaceholderURL  = "https://cos143.y3sh.com/bookPlaceholder.png"
+	googleBooksCoverURL = "https://www.googleapis.com/books/v1/volumes?q=isbn:%s&key=%s"
+	bookCoverCapacity   = 5000
+	coverFoundTTL       = 7 * 24 * time.Hour
+	coverNotFoundTTL    = 6 * time.Hour
+	coverErrorTTL       = 5 * time.Minute
+)
+
+var listNamePattern = regexp.MustCompile(`^[a-z0-9-]{1,64}$`)
+
 type BestSellerRes struct {
 	Status       string   `json:"status"`
 	Copyright    string   `json:"copyright"`
@@ -35,6 +60,30 @@ type Result struct {
 	Reviews          []Review     `json:"reviews"`
 }
 
+type ListNamesRes struct {
+	Status     string     `json:"status"`
+	Copyright  string     `json:"copyright"`
+	NumResults int64      `json:"num_results"`
+	Results    []ListName `json:"results"`
+}
+
+type ListName struct {
+	ListName            string `json:"list_name"`
+	DisplayName         string `json:"display_name"`
+	ListNameEncoded     string `json:"list_name_encoded"`
+	OldestPublishedDate string `json:"oldest_published_date"`
+	NewestPublishedDate string `json:"newest_published_date"`
+	Updated             string `json:"updated"`
+}
+
+type SimpleList struct {
+	Name                string `json:"name"`
+	DisplayName         string `json:"displayName"`
+	Updated             string `json:"updated"`
+	OldestPublishedDate string `json:"oldestPublishedDate"`
+	NewestPublishedDate string `json:"newestPublishedDate"`
+}
+
 type BookDetail struct {
 	Title           string `json:"title"`
 	Description     string `json:"description"`
@@ -70,6 +119,28 @@ type SimpleBook struct {
 	Author           string `json:"author"`
 	Description      string `json:"description"`
 	Isbn             string `json:"isbn"`
+	Isbn10           string `json:"isbn10"`
+	Isbn13           string `json:"isbn13"`
+	ListName         string `json:"listName"`
+	PublishedDate    string `json:"publishedDate"`
+	BestsellersDate  string `json:"bestsellersDate"`
+}
+
+// BookRank is a single week of a book's position on one best-seller list.
+type BookRank struct {
+	ListName        string `json:"listName"`
+	PublishedDate   string `json:"publishedDate"`
+	BestsellersDate string `json:"bestsellersDate"`
+	Rank            int64  `json:"rank"`
+	LastWeekRank    int64  `json:"lastWeekRank"`
+	WeeksOnList     int64  `json:"weeksOnList"`
+}
+
+type BookHistory struct {
+	Isbn10 string     `json:"isbn10"`
+	Isbn13 string     `json:"isbn13"`
+	Title  string     `json:"title"`
+	Ranks  []BookRank `json:"ranks"`
 }
 
 type BookCoverURL struct {
@@ -86,44 +157,75 @@ type GoogleBookRes struct {
 	} `json:"items"`
 }
 
-type HTTPClient interface {
-	Get(url string) (resp *http.Response, err error)
-	Do(req *http.Request) (resp *http.Response, err error)
-}
-
 type RestClient struct {
 	bestSellerAPIKey string
 	googleBookAPIKey string
-	lastBestSellers  BestSellerRes
-	isbnCoverURLMap  sync.Map // not thread safe
-	httpClient       HTTPClient
+	bestSellers      *bestSellerCache
+	listNamesMutex   *sync.Mutex
+	lastListNames    []SimpleList
+	listNamesFetched time.Time
+	bookCovers       *cache.LRU
+	coverStore       objectStore
+	coverFlights     *cache.Group
+	placeholderMutex *sync.Mutex
+	placeholders     map[int]CoverImage
+	searches         *cache.LRU
+	searchFlights    *cache.Group
+	httpClient       upstream.HTTPClient
 }
 
-func NewRestClient(bestSellerAPIKey, googleBookAPIKey string, httpClient HTTPClient) *RestClient {
-	return &RestClient{
+func NewRestClient(bestSellerAPIKey, googleBookAPIKey string, httpClient upstream.HTTPClient) *RestClient {
+	r := &RestClient{
 		bestSellerAPIKey: bestSellerAPIKey,
 		googleBookAPIKey: googleBookAPIKey,
-		isbnCoverURLMap:  sync.Map{},
+		listNamesMutex:   &sync.Mutex{},
+		bookCovers:       cache.NewLRU(bookCoverCapacity),
+		coverFlights:     cache.NewGroup(),
+		placeholderMutex: &sync.Mutex{},
+		placeholders:     make(map[int]CoverImage),
+		searches:         cache.NewLRU(searchCapacity),
+		searchFlights:    cache.NewGroup(),
 		httpClient:       httpClient,
 	}
+	r.bestSellers = newBestSellerCache(r.fetchBestSellers)
+
+	return r
+}
+
+// SetBestSellersTTL sets how long a list is served without refreshing and
+// how much longer a stale list may be served while it refreshes.
+func (r *RestClient) SetBestSellersTTL(ttl, staleTTL time.Duration) {
+	r.bestSellers.ttl = ttl
+	r.bestSellers.staleTTL = staleTTL
 }
 
-func (r *RestClient) GetSimpleBestSellers() []SimpleBook {
+// PersistBestSellersTo stores list snapshots so a restart can serve them
+// without spending API quota.
+func (r *RestClient) PersistBestSellersTo(store snapshotStore) {
+	r.bestSellers.store = store
+}
+
+// IsValidListName reports whether listName looks like an encoded NYT list
+// name such as hardcover-fiction.
+func IsValidListName(listName string) bool {
+	return listNamePattern.MatchString(listName)
+}
+
+// GetSimpleBestSellers returns the list published on date (YYYY-MM-DD), or
+// the current list when date is empty.
+func (r *RestClient) GetSimpleBestSellers(listName, date string) []SimpleBook {
 	var books []SimpleBook
 
-	bestSellers := r.getBestSellers()
+	bestSellers := r.bestSellers.Get(listName, date)
 	for i := range bestSellers.Results {
 		bookObj := bestSellers.Results[i]
 		if len(bookObj.BookDetails) > 0 {
 			bookInfo := bookObj.BookDetails[0]
+			isbn10, isbn13 := bookObj.isbns()
 
-			isbn := "000"
-			if len(bookObj.Isbns) > 0 {
-				isbn = bookObj.Isbns[0].Isbn10
-			}
-
-			if isbn == "000" || isbn == "" && len(bookObj.BookDetails) > 0 {
-				isbn = bookObj.BookDetails[0].PrimaryIsbn10
+			bookISBN := isbn10
+			if bookISBN == "" {
+				bookISBN = isbn13
 			}
 
 			week := bookObj.RankLastWeek
@@ -136,7 +238,12 @@ func (r *RestClient) GetSimpleBestSellers() []SimpleBook {
 				Title:            bookInfo.Title,
 				Author:           bookInfo.Author,
 				Description:      bookInfo.Description,
-				Isbn:             isbn,
+				Isbn:             bookISBN,
+				Isbn10:           isbn10,
+				Isbn13:           isbn13,
+				ListName:         bookObj.ListName,
+				PublishedDate:    bookObj.PublishedDate,
+				BestsellersDate:  bookObj.BestsellersDate,
 			})
 		}
 	}
@@ -145,102 +252,212 @@ func (r *RestClient) GetSimpleBestSellers() []SimpleBook {
 }
 
 func (r *RestClient) GetBookCoverURL(isbn string) BookCoverURL {
-	storedURLIf, _ := r.isbnCoverURLMap.Load(isbn)
-	if storedURL, ok := storedURLIf.(string); ok {
-		if storedURL != "" {
-			return BookCoverURL{URL: storedURL}
-		}
+	if cached, ok := r.bookCovers.Get(isbn); ok {
+		return BookCoverURL{URL: cached.(string)}
 	}
 
-	defaultURL := BookCoverURL{
-		URL: "https://cos143.y3sh.com/bookPlaceholder.png",
+	googleBookRes := GoogleBookRes{}
+	err := r.fetchJSON(fmt.Sprintf(googleBooksCoverURL, isbn, r.googleBookAPIKey), &googleBookRes)
+	if upstream.IsCircuitOpen(err) {
+		// Google Books is known to be down, skip caching so the real cover is
+		// found as soon as it recovers.
+		return BookCoverURL{URL: BookPlaceholderURL}
 	}
-	url := fmt.Sprintf("https://www.googleapis.com/books/v1/volumes?q=isbn:%s&key=%s", isbn, r.googleBookAPIKey)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		log.Errorf("Could not make req for googleBookRes, %s", err.Error())
-		return defaultURL
+		log.Errorf("Could not fetch googleBookRes, %s", err.Error())
+		r.bookCovers.Set(isbn, BookPlaceholderURL, coverErrorTTL)
+
+		return BookCoverURL{URL: BookPlaceholderURL}
 	}
 
-	res, err := r.httpClient.Do(req)
+	items := googleBookRes.Items
+	if len(items) == 0 || items[0].VolumeInfo.ImageLinks.Thumbnail == "" {
+		r.bookCovers.Set(isbn, BookPlaceholderURL, coverNotFoundTTL)
+
+		return BookCoverURL{URL: BookPlaceholderURL}
+	}
+
+	thumbnail := toHTTPS(items[0].VolumeInfo.ImageLinks.Thumbnail)
+	r.bookCovers.Set(isbn, thumbnail, coverFoundTTL)
+
+	return BookCoverURL{URL: thumbnail}
+}
+
+// CacheStats reports hit and miss counts for monitoring.
+func (r *RestClient) CacheStats() map[string]cache.Stats {
+	return map[string]cache.Stats{
+		"bookCovers": r.bookCovers.Stats(),
+		"searches":   r.searches.Stats(),
+	}
+}
+
+func (r *RestClient) GetListNames() []SimpleList {
+	r.listNamesMutex.Lock()
+	defer r.listNamesMutex.Unlock()
+
+	if len(r.lastListNames) > 0 && time.Since(r.listNamesFetched) < listNamesMaxAge {
+		return r.lastListNames
+	}
+
+	listNamesRes := ListNamesRes{}
+	err := r.fetchJSON(fmt.Sprintf(listNamesURL, r.bestSellerAPIKey), &listNamesRes)
 	if err != nil {
-		log.Errorf("Could not fetch googleBookRes, %s", err.Error())
-		return defaultURL
+		log.Errorf("Could not fetch list names, %s", err.Error())
+		if r.lastListNames == nil {
+			return []SimpleList{}
+		}
+
+		return r.lastListNames
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Errorf("Non 200 status on fetch googleBookRes: , %d", res.StatusCode)
-		return defaultURL
+
+	lists := []SimpleList{}
+	for _, list := range listNamesRes.Results {
+		lists = append(lists, SimpleList{
+			Name:                list.ListNameEncoded,
+			DisplayName:         list.DisplayName,
+			Updated:             list.Updated,
+			OldestPublishedDate: list.OldestPublishedDate,
+			NewestPublishedDate: list.NewestPublishedDate,
+		})
 	}
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Errorf("Could not read googleBookRes, %s", err.Error())
-		return defaultURL
+	r.lastListNames = lists
+	r.listNamesFetched = time.Now()
+
+	return lists
+}
+
+// GetBookHistory aggregates the weekly ranks of a book across every list
+// snapshot held in memory or persisted, oldest first.
+func (r *RestClient) GetBookHistory(bookIsbn string) BookHistory {
+	history := BookHistory{
+		Ranks: []BookRank{},
 	}
 
-	googleBookRes := GoogleBookRes{}
-	jsonErr := json.Unmarshal(body, &googleBookRes)
-	if jsonErr != nil {
-		log.Errorf("Could not unmarshall googleBookRes, %s", err.Error())
-		return defaultURL
+	var err error
+
+	history.Isbn10, history.Isbn13, err = isbn.Both(bookIsbn)
+	if err != nil {
+		return history
 	}
 
-	items := googleBookRes.Items
-	thumbnail := defaultURL.URL
-	if len(items) > 0 {
-		thumbnail = items[0].VolumeInfo.ImageLinks.Thumbnail
+	seen := make(map[string]bool)
+
+	for _, snapshot := range r.bestSellers.Snapshots() {
+		for i := range snapshot.Results {
+			result := snapshot.Results[i]
+			if !result.hasIsbn13(history.Isbn13) {
+				continue
+			}
+
+			seenKey := fmt.Sprintf("%s:%s", result.ListName, result.PublishedDate)
+			if seen[seenKey] {
+				continue
+			}
+			seen[seenKey] = true
+
+			if history.Title == "" && len(result.BookDetails) > 0 {
+				history.Title = result.BookDetails[0].Title
+			}
+
+			history.Ranks = append(history.Ranks, BookRank{
+				ListName:        result.ListName,
+				PublishedDate:   result.PublishedDate,
+				BestsellersDate: result.BestsellersDate,
+				Rank:            result.Rank,
+				LastWeekRank:    result.RankLastWeek,
+				WeeksOnList:     result.WeeksOnList,
+			})
+		}
 	}
 
-	r.isbnCoverURLMap.Store(isbn, thumbnail)
+	sort.Slice(history.Ranks, func(i, j int) bool {
+		a, b := history.Ranks[i], history.Ranks[j]
+		if a.PublishedDate != b.PublishedDate {
+			return a.PublishedDate < b.PublishedDate
+		}
 
-	return BookCoverURL{URL: thumbnail}
+		return a.ListName < b.ListName
+	})
+
+	return history
+}
+
+func (res *Result) hasIsbn13(isbn13 string) bool {
+	for _, candidate := range res.candidateIsbns() {
+		if converted, err := isbn.To13(candidate); err == nil && converted == isbn13 {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (r *RestClient) getBestSellers() BestSellerRes {
-	if len(r.lastBestSellers.Results) > 0 {
-		bTimeStr := r.lastBestSellers.Results[0].BestsellersDate
-		bTime, _ := time.Parse("2006-01-02", bTimeStr)
-		now := time.Now()
-		if now.Sub(bTime).Hours() > 6 {
-			return r.lastBestSellers
+// isbns returns both forms of the first valid ISBN NYT lists for the book.
+func (res *Result) isbns() (isbn10, isbn13 string) {
+	for _, candidate := range res.candidateIsbns() {
+		if ten, thirteen, err := isbn.Both(candidate); err == nil {
+			return ten, thirteen
 		}
 	}
 
-	url := fmt.Sprintf("https://api.nytimes.com/svc/books/v3/lists.json?list-name=hardcover-fiction&api-key=%s", r.bestSellerAPIKey)
+	return "", ""
+}
+
+func (res *Result) candidateIsbns() []string {
+	var candidates []string
+	for _, bookIsbn := range res.Isbns {
+		candidates = append(candidates, bookIsbn.Isbn10, bookIsbn.Isbn13)
+	}
+
+	for _, detail := range res.BookDetails {
+		candidates = append(candidates, detail.PrimaryIsbn10, detail.PrimaryIsbn13)
+	}
+
+	return candidates
+}
+
+func (r *RestClient) fetchBestSellers(listName, date string) (BestSellerRes, error) {
+	bestSellersReqURL := fmt.Sprintf(bestSellersURL, url.QueryEscape(listName), r.bestSellerAPIKey)
+	if date != "" {
+		bestSellersReqURL += fmt.Sprintf(publishedDateQS, date)
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	bestSellerRes := BestSellerRes{}
+	err := r.fetchJSON(bestSellersReqURL, &bestSellerRes)
+	if err != nil {
+		return bestSellerRes, errors.Trace(err)
+	}
+
+	return bestSellerRes, nil
+}
+
+func (r *RestClient) fetchJSON(reqURL string, target interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
 	if err != nil {
-		log.Errorf("Could not make req for best sellers, %s", err.Error())
-		return r.lastBestSellers
+		return errors.Trace(err)
 	}
 
 	res, err := r.httpClient.Do(req)
 	if err != nil {
-		log.Errorf("Could not fetch best sellers, %s", err.Error())
-		return r.lastBestSellers
+		return errors.Trace(err)
 	}
 	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Errorf("Non 200 status on fetch best sellers: , %d", res.StatusCode)
-		return r.lastBestSellers
-	}
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Errorf("Could not read best sellers, %s", err.Error())
-		return r.lastBestSellers
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("non 200 status: %d", res.StatusCode)
 	}
 
-	bestSellerRes := BestSellerRes{}
-	jsonErr := json.Unmarshal(body, &bestSellerRes)
-	if jsonErr != nil {
-		log.Errorf("Could not unmarshall best sellers, %s", err.Error())
-		return r.lastBestSellers
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Trace(err)
 	}
 
-	r.lastBestSellers = bestSellerRes
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		return errors.Annotate(err, "could not unmarshal response")
+	}
 
-	return bestSellerRes
+	return nil
 }
@@ -0,0 +1,238 @@
+package nytimes
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	reviewsURL       = "https://api.nytimes.com/svc/books/v3/reviews.json"
+	articleSearchURL = "https://api.nytimes.com/svc/search/v2/articlesearch.json"
+	nyTimesSiteURL   = "https://www.nytimes.com/"
+	reviewsTTL       = 24 * time.Hour
+	articlesTTL      = 15 * time.Minute
+	searchCapacity   = 1000
+	MaxArticlePage   = 100
+)
+
+type ReviewsRes struct {
+	Status     string         `json:"status"`
+	Copyright  string         `json:"copyright"`
+	NumResults int64          `json:"num_results"`
+	Results    []ReviewResult `json:"results"`
+}
+
+type ReviewResult struct {
+	URL             string   `json:"url"`
+	PublicationDate string   `json:"publication_dt"`
+	Byline          string   `json:"byline"`
+	BookTitle       string   `json:"book_title"`
+	BookAuthor      string   `json:"book_author"`
+	Summary         string   `json:"summary"`
+	Isbn13          []string `json:"isbn13"`
+}
+
+type SimpleReview struct {
+	URL             string   `json:"url"`
+	PublicationDate string   `json:"publicationDate"`
+	Byline          string   `json:"byline"`
+	BookTitle       string   `json:"bookTitle"`
+	BookAuthor      string   `json:"bookAuthor"`
+	Summary         string   `json:"summary"`
+	Isbn13s         []string `json:"isbn13s"`
+}
+
+type ReviewQuery struct {
+	Isbn   string
+	Title  string
+	Author string
+}
+
+type ArticleSearchRes struct {
+	Status   string `json:"status"`
+	Response struct {
+		Docs []ArticleDoc `json:"docs"`
+		Meta struct {
+			Hits   int64 `json:"hits"`
+			Offset int64 `json:"offset"`
+		} `json:"meta"`
+	} `json:"response"`
+}
+
+type ArticleDoc struct {
+	ID            string `json:"_id"`
+	WebURL        string `json:"web_url"`
+	Snippet       string `json:"snippet"`
+	Abstract      string `json:"abstract"`
+	LeadParagraph string `json:"lead_paragraph"`
+	Source        string `json:"source"`
+	PubDate       string `json:"pub_date"`
+	SectionName   string `json:"section_name"`
+	Headline      struct {
+		Main string `json:"main"`
+	} `json:"headline"`
+	Byline struct {
+		Original string `json:"original"`
+	} `json:"byline"`
+	Multimedia []struct {
+		URL     string `json:"url"`
+		Type    string `json:"type"`
+		Subtype string `json:"subtype"`
+	} `json:"multimedia"`
+}
+
+type SimpleArticle struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Headline      string `json:"headline"`
+	Abstract      string `json:"abstract"`
+	Snippet       string `json:"snippet"`
+	Byline        string `json:"byline"`
+	Section       string `json:"section"`
+	Source        string `json:"source"`
+	PublishedDate string `json:"publishedDate"`
+	ImageURL      string `json:"imageUrl"`
+}
+
+type ArticleSearchResult struct {
+	Hits     int64           `json:"hits"`
+	Page     int             `json:"page"`
+	Articles []SimpleArticle `json:"articles"`
+}
+
+// GetReviews finds NYT book reviews by ISBN, title or author, in that order
+// of preference.
+func (r *RestClient) GetReviews(query ReviewQuery) ([]SimpleReview, error) {
+	params := url.Values{}
+
+	switch {
+	case query.Isbn != "":
+		params.Set("isbn", query.Isbn)
+	case query.Title != "":
+		params.Set("title", query.Title)
+	case query.Author != "":
+		params.Set("author", query.Author)
+	default:
+		return nil, errors.NotValidf("empty review query")
+	}
+
+	reviewsIf, err := r.getCached(reviewsURL, params, reviewsTTL, func(reqURL string) (interface{}, error) {
+		reviewsRes := ReviewsRes{}
+		err := r.fetchJSON(reqURL, &reviewsRes)
+		if err != nil {
+			return nil, errors.Annotate(err, "could not fetch reviews")
+		}
+
+		reviews := []SimpleReview{}
+		for _, review := range reviewsRes.Results {
+			reviews = append(reviews, SimpleReview{
+				URL:             review.URL,
+				PublicationDate: review.PublicationDate,
+				Byline:          review.Byline,
+				BookTitle:       review.BookTitle,
+				BookAuthor:      review.BookAuthor,
+				Summary:         review.Summary,
+				Isbn13s:         review.Isbn13,
+			})
+		}
+
+		return reviews, nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return reviewsIf.([]SimpleReview), nil
+}
+
+// SearchArticles runs an NYT Article Search, page being the zero based page
+// of ten results.
+func (r *RestClient) SearchArticles(query string, page int) (ArticleSearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return ArticleSearchResult{}, errors.NotValidf("empty article query")
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("page", fmt.Sprint(page))
+
+	resultIf, err := r.getCached(articleSearchURL, params, articlesTTL, func(reqURL string) (interface{}, error) {
+		articleRes := ArticleSearchRes{}
+		err := r.fetchJSON(reqURL, &articleRes)
+		if err != nil {
+			return nil, errors.Annotate(err, "could not search articles")
+		}
+
+		result := ArticleSearchResult{
+			Hits:     articleRes.Response.Meta.Hits,
+			Page:     page,
+			Articles: []SimpleArticle{},
+		}
+		for i := range articleRes.Response.Docs {
+			result.Articles = append(result.Articles, articleRes.Response.Docs[i].Simple())
+		}
+
+		return result, nil
+	})
+	if err != nil {
+		return ArticleSearchResult{}, errors.Trace(err)
+	}
+
+	return resultIf.(ArticleSearchResult), nil
+}
+
+func (d *ArticleDoc) Simple() SimpleArticle {
+	article := SimpleArticle{
+		ID:            d.ID,
+		URL:           d.WebURL,
+		Headline:      d.Headline.Main,
+		Abstract:      d.Abstract,
+		Snippet:       d.Snippet,
+		Byline:        d.Byline.Original,
+		Section:       d.SectionName,
+		Source:        d.Source,
+		PublishedDate: d.PubDate,
+	}
+
+	for _, media := range d.Multimedia {
+		if media.Type == "image" && media.URL != "" {
+			article.ImageURL = media.URL
+			if !strings.HasPrefix(media.URL, "http") {
+				article.ImageURL = nyTimesSiteURL + media.URL
+			}
+
+			break
+		}
+	}
+
+	return article
+}
+
+// getCached serves a simplified response from the search cache, letting only
+// one concurrent caller fetch it upstream on a miss.
+func (r *RestClient) getCached(baseURL string, params url.Values, ttl time.Duration,
+	load func(reqURL string) (interface{}, error)) (interface{}, error) {
+	key := fmt.Sprintf("%s?%s", baseURL, params.Encode())
+	if cached, ok := r.searches.Get(key); ok {
+		return cached, nil
+	}
+
+	value, _, err := r.searchFlights.Do(key, func() (interface{}, error) {
+		params.Set("api-key", r.bestSellerAPIKey)
+
+		loaded, err := load(fmt.Sprintf("%s?%s", baseURL, params.Encode()))
+		if err != nil {
+			return nil, err
+		}
+
+		r.searches.Set(key, loaded, ttl)
+
+		return loaded, nil
+	})
+
+	return value, err
+}
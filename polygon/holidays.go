@@ -0,0 +1,102 @@
+package polygon
+
+import "time"
+
+// marketClosures are the days NYSE closed outside its holiday schedule since
+// simulationStart.
+var marketClosures = map[string]bool{
+	"2001-09-11": true,
+	"2001-09-12": true,
+	"2001-09-13": true,
+	"2001-09-14": true,
+	"2004-06-11": true,
+	"2007-01-02": true,
+	"2012-10-29": true,
+	"2012-10-30": true,
+	"2018-12-05": true,
+	"2025-01-09": true,
+}
+
+// isMarketHoliday reports whether date is an NYSE holiday, as observed when
+// it falls on a weekend, or an unscheduled closure.
+func isMarketHoliday(date time.Time) bool {
+	year, month, day := date.Date()
+
+	if marketClosures[date.Format(dateFormat)] {
+		return true
+	}
+
+	holidays := []time.Time{
+		// New Year's Day moves to Monday, but not back to the prior Friday.
+		observedHoliday(year, time.January, 1, false),
+		nthWeekday(year, time.January, time.Monday, 3),
+		nthWeekday(year, time.February, time.Monday, 3),
+		easter(year).AddDate(0, 0, -2),
+		lastWeekday(year, time.May, time.Monday),
+		observedHoliday(year, time.July, 4, true),
+		nthWeekday(year, time.September, time.Monday, 1),
+		nthWeekday(year, time.November, time.Thursday, 4),
+		observedHoliday(year, time.December, 25, true),
+	}
+
+	if year >= 2022 {
+		holidays = append(holidays, observedHoliday(year, time.June, 19, true))
+	}
+
+	for _, holiday := range holidays {
+		if holiday.Month() == month && holiday.Day() == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// observedHoliday moves a holiday on Sunday to Monday, and one on Saturday to
+// Friday when toFriday is set.
+func observedHoliday(year int, month time.Month, day int, toFriday bool) time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	switch date.Weekday() {
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	case time.Saturday:
+		if toFriday {
+			return date.AddDate(0, 0, -1)
+		}
+	}
+
+	return date
+}
+
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	date := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(weekday) - int(date.Weekday()) + 7) % 7
+
+	return date.AddDate(0, 0, offset+(n-1)*7)
+}
+
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	date := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+	offset := (int(date.Weekday()) - int(weekday) + 7) % 7
+
+	return date.AddDate(0, 0, -offset)
+}
+
+// easter is Easter Sunday in the Gregorian calendar, by the anonymous
+// Gregorian algorithm.
+func easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
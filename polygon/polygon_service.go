@@ -1,72 +1,198 @@
 package polygon
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/cache"
+	"github.com/y3sh/go143/httpcache"
+	"github.com/y3sh/go143/upstream"
 )
 
 const (
-	polygonAPIBase = "https://api.polygon.io/"
+	polygonAPIBase  = "https://api.polygon.io/"
+	apiKeyParam     = "apiKey"
+	redactedAPIKey  = "REDACTED"
+	defaultCacheTTL = time.Minute
 )
 
-type HTTPClient interface {
-	Get(url string) (resp *http.Response, err error)
-	Do(req *http.Request) (resp *http.Response, err error)
+// forwardedHeaders are the upstream response headers passed on to clients.
+var forwardedHeaders = []string{
+	"Content-Type",
+	"Retry-After",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset",
+	httpcache.HeaderXCache,
+}
+
+// Response is an upstream Polygon response, successful or not.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
 }
 
 type RestClient struct {
 	polygonAPIKey string
-	httpClient    HTTPClient
+	httpClient    upstream.HTTPClient
+	limited       *limitedClient
+	flights       *cache.Group
 }
 
-func NewRestClient(polygonAPIKey string, httpClient HTTPClient) *RestClient {
+// NewRestClient caches Polygon responses in httpCache, in front of the rate
+// limit, so only requests that reach Polygon spend a token.
+func NewRestClient(polygonAPIKey string, httpClient upstream.HTTPClient, httpCache *httpcache.Store) *RestClient {
+	limited := &limitedClient{
+		next:    httpClient,
+		limiter: newTokenBucket(DefaultRequestsPerMinute, DefaultBurst),
+	}
+
+	cachingClient := httpcache.NewClient(limited, httpCache)
+	cachingClient.SetDefaultTTL(routeTTL)
+
 	return &RestClient{
 		polygonAPIKey: polygonAPIKey,
-		httpClient:    httpClient,
+		httpClient:    cachingClient,
+		limited:       limited,
+		flights:       cache.NewGroup(),
 	}
 }
 
-func (r *RestClient) GetPolygonPath(path string) []byte {
-	polygonURL := fmt.Sprintf("%s%s", polygonAPIBase, r.withToken(path))
+// SetRateLimit sets the local budget of upstream requests, which should match
+// the Polygon plan the API key is on.
+func (r *RestClient) SetRateLimit(requestsPerMinute, burst int) {
+	r.limited.limiter = newTokenBucket(requestsPerMinute, burst)
+}
+
+// DisableRateLimit lifts the local budget, for upstreams that are not Polygon
+// itself, like the fixture replayer.
+func (r *RestClient) DisableRateLimit() {
+	r.limited.limiter = nil
+}
+
+// GetPolygonPath fetches path from Polygon. Any upstream status is returned as
+// a Response; an error means Polygon could not be reached at all, or that the
+// local rate limit was hit, in which case it is a *RateLimitError.
+//
+// Responses are cached following Polygon's caching headers, falling back to
+// the route's TTL for successful responses without any, and identical
+// concurrent requests share one upstream call.
+func (r *RestClient) GetPolygonPath(path string) (*Response, error) {
+	res, _, err := r.flights.Do(path, func() (interface{}, error) {
+		return r.fetch(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.(*Response), nil
+}
 
-	req, err := http.NewRequest(http.MethodGet, polygonURL, nil)
+func (r *RestClient) fetch(path string) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, polygonAPIBase+r.withToken(path), nil)
 	if err != nil {
-		log.Errorf("Could not make req for googleBookRes, %s", err.Error())
-		return nil
+		return nil, errors.Annotatef(err, "could not make req for %s", path)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	res, err := r.httpClient.Do(req)
 	if err != nil {
-		log.Errorf("Could not fetch %s, %s", path, err.Error())
-		return nil
+		return nil, errors.Annotatef(r.redactError(err), "could not fetch %s", path)
 	}
-
 	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Errorf("Non 200 status: %d, %s", res.StatusCode, path)
-		return nil
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Annotatef(r.redactError(err), "could not read %s", path)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		log.Warnf("Non 200 status: %d, %s", res.StatusCode, path)
+	}
+
+	header := http.Header{}
+	for _, name := range forwardedHeaders {
+		if value := res.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
 	}
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Errorf("Could not read polygon res: %s, %s", path, err.Error())
-		return nil
+	return &Response{
+		StatusCode: res.StatusCode,
+		Header:     header,
+		Body:       r.redact(body),
+	}, nil
+}
+
+// IsTimeout reports whether err from GetPolygonPath was caused by Polygon not
+// answering in time.
+func IsTimeout(err error) bool {
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded {
+		return true
+	}
+
+	netErr, ok := cause.(net.Error)
+
+	return ok && netErr.Timeout()
+}
+
+// routeTTL is how long a successful response without caching headers stays
+// fresh.
+func routeTTL(req *http.Request, res *http.Response) time.Duration {
+	if res.StatusCode != http.StatusOK {
+		return 0
+	}
+
+	return cacheTTL(strings.TrimPrefix(req.URL.Path, "/"))
+}
+
+func cacheTTL(path string) time.Duration {
+	routePath := strings.SplitN(path, "?", 2)[0]
+	if route, _ := findRoute(routePath); route != nil && route.TTL > 0 {
+		return route.TTL
+	}
+
+	return defaultCacheTTL
+}
+
+func (r *RestClient) withToken(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	return fmt.Sprintf("%s%s%s=%s", path, separator, apiKeyParam, url.QueryEscape(r.polygonAPIKey))
+}
+
+// redactError strips the API key from the request URL net/http puts in its
+// errors so it never reaches logs or responses.
+func (r *RestClient) redactError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		redacted := *urlErr
+		redacted.URL = string(r.redact([]byte(urlErr.URL)))
+
+		return &redacted
 	}
 
-	return body
+	return err
 }
 
-func (r *RestClient) withToken(url string) string {
-	urlWithToken := fmt.Sprintf("%s?apiKey=%s", url, r.polygonAPIKey)
-	if strings.Contains(url, "?") {
-		urlWithToken = fmt.Sprintf("%s&apiKey=%s", url, r.polygonAPIKey)
+func (r *RestClient) redact(body []byte) []byte {
+	if r.polygonAPIKey == "" {
+		return body
 	}
 
-	return urlWithToken
+	return bytes.ReplaceAll(body, []byte(r.polygonAPIKey), []byte(redactedAPIKey))
 }
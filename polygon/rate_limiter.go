@@ -0,0 +1,101 @@
+package polygon
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/y3sh/go143/upstream"
+)
+
+const (
+	DefaultRequestsPerMinute = 5
+	DefaultBurst             = 5
+)
+
+// RateLimitError is returned when the local Polygon request budget is spent.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("polygon rate limit reached, retry after %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds RetryAfter up for the Retry-After header.
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// tokenBucket allows burst requests at once, refilling one token every
+// interval.
+type tokenBucket struct {
+	mutex    *sync.Mutex
+	capacity float64
+	tokens   float64
+	interval time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(requestsPerMinute, burst int) *tokenBucket {
+	if requestsPerMinute < 1 {
+		requestsPerMinute = 1
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		mutex:    &sync.Mutex{},
+		capacity: float64(burst),
+		tokens:   float64(burst),
+		interval: time.Minute / time.Duration(requestsPerMinute),
+		last:     time.Now(),
+	}
+}
+
+// Take spends a token, or reports how long until the next one is available.
+func (b *tokenBucket) Take() (bool, time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.capacity, b.tokens+float64(now.Sub(b.last))/float64(b.interval))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) * float64(b.interval))
+}
+
+// limitedClient spends a token for every request sent to next, failing with a
+// *RateLimitError once they run out. A nil limiter allows every request.
+type limitedClient struct {
+	next    upstream.HTTPClient
+	limiter *tokenBucket
+}
+
+func (c *limitedClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *limitedClient) Do(req *http.Request) (*http.Response, error) {
+	if c.limiter != nil {
+		if ok, retryAfter := c.limiter.Take(); !ok {
+			return nil, &RateLimitError{RetryAfter: retryAfter}
+		}
+	}
+
+	return c.next.Do(req)
+}
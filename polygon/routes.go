@@ -0,0 +1,267 @@
+package polygon
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	dateFormat    = "2006-01-02"
+	maxMultiplier = 1000
+	maxLimit      = 50000
+)
+
+// Route is a Polygon endpoint students may reach through the proxy. Path
+// segments in braces are validated by paramValidators and only the listed
+// query params are forwarded.
+type Route struct {
+	Path        string        `json:"path"`
+	Description string        `json:"description"`
+	Query       []string      `json:"query,omitempty"`
+	TTL         time.Duration `json:"-"`
+	segments    []string
+}
+
+var (
+	tickerPattern = regexp.MustCompile(`^[A-Z][A-Z0-9.]{0,9}$`)
+
+	timespans = []string{"minute", "hour", "day", "week", "month", "quarter", "year"}
+
+	// maxRanges bounds aggregate requests so one call can't ask for years of
+	// minute bars.
+	maxRanges = map[string]time.Duration{
+		"minute": 31 * 24 * time.Hour,
+		"hour":   366 * 24 * time.Hour,
+	}
+	defaultMaxRange = 5 * 366 * 24 * time.Hour
+
+	Routes = []*Route{
+		{
+			Path:        "v2/aggs/ticker/{ticker}/range/{multiplier}/{timespan}/{from}/{to}",
+			Description: "Aggregate bars for a ticker over a date range",
+			Query:       []string{"adjusted", "sort", "limit"},
+			TTL:         time.Hour,
+		},
+		{
+			Path:        "v2/aggs/ticker/{ticker}/prev",
+			Description: "Previous day open, high, low and close for a ticker",
+			Query:       []string{"adjusted"},
+			TTL:         15 * time.Minute,
+		},
+		{
+			Path:        "v1/open-close/{ticker}/{date}",
+			Description: "Open, close and after hours prices of a ticker on a date",
+			Query:       []string{"adjusted"},
+			TTL:         24 * time.Hour,
+		},
+		{
+			Path:        "v2/aggs/grouped/locale/us/market/stocks/{date}",
+			Description: "Daily bars for every US stock on a date",
+			Query:       []string{"adjusted"},
+			TTL:         24 * time.Hour,
+		},
+		{
+			Path:        "v3/reference/tickers/{ticker}",
+			Description: "Details of a ticker",
+			TTL:         24 * time.Hour,
+		},
+		{
+			Path:        "v3/reference/tickers",
+			Description: "Search tickers by name or symbol",
+			Query:       []string{"search", "ticker", "type", "market", "active", "sort", "order", "limit"},
+			TTL:         time.Hour,
+		},
+		{
+			Path:        "v1/marketstatus/now",
+			Description: "Current trading status of the markets",
+			TTL:         time.Minute,
+		},
+	}
+)
+
+func init() {
+	for _, route := range Routes {
+		route.segments = strings.Split(route.Path, "/")
+	}
+}
+
+// MatchRoute finds the allowlisted route for path and validates its path and
+// query params. It returns a Forbidden error for paths that are not
+// allowlisted and a BadRequest error for invalid params.
+func MatchRoute(path string, query url.Values) (*Route, error) {
+	route, params := findRoute(path)
+	if route == nil {
+		return nil, errors.Forbiddenf("polygon path %q is not allowed, see / for the supported routes", path)
+	}
+
+	err := validateParams(params)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = route.validateQuery(query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return route, nil
+}
+
+func findRoute(path string) (*Route, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, route := range Routes {
+		if params, ok := route.match(segments); ok {
+			return route, params
+		}
+	}
+
+	return nil, nil
+}
+
+func (route *Route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(route.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range route.segments {
+		if strings.HasPrefix(segment, "{") {
+			params[strings.Trim(segment, "{}")] = segments[i]
+			continue
+		}
+
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func (route *Route) validateQuery(query url.Values) error {
+	for name, values := range query {
+		if !route.allowsQuery(name) {
+			return errors.BadRequestf("query param %q is not supported on %s", name, route.Path)
+		}
+
+		// Every value is forwarded, so a repeat could smuggle an unchecked one.
+		if len(values) != 1 {
+			return errors.BadRequestf("query param %q may only be given once", name)
+		}
+
+		value := values[0]
+
+		switch name {
+		case "adjusted", "active":
+			if value != "true" && value != "false" {
+				return errors.BadRequestf("%s must be true or false", name)
+			}
+		case "sort", "order":
+			if route.Path != "v3/reference/tickers" && value != "asc" && value != "desc" {
+				return errors.BadRequestf("%s must be asc or desc", name)
+			}
+		case "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > maxLimit {
+				return errors.BadRequestf("limit must be 1-%d", maxLimit)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (route *Route) allowsQuery(name string) bool {
+	for _, allowed := range route.Query {
+		if name == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateParams(params map[string]string) error {
+	if ticker, ok := params["ticker"]; ok && !tickerPattern.MatchString(ticker) {
+		return errors.BadRequestf("ticker %q must be 1-10 upper case letters, digits or dots", ticker)
+	}
+
+	if multiplier, ok := params["multiplier"]; ok {
+		value, err := strconv.Atoi(multiplier)
+		if err != nil || value < 1 || value > maxMultiplier {
+			return errors.BadRequestf("multiplier must be 1-%d", maxMultiplier)
+		}
+	}
+
+	if timespan, ok := params["timespan"]; ok && !isTimespan(timespan) {
+		return errors.BadRequestf("timespan must be one of %s", strings.Join(timespans, ", "))
+	}
+
+	if date, ok := params["date"]; ok {
+		if _, err := parseDate("date", date); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if _, ok := params["from"]; ok {
+		return validateRange(params["from"], params["to"], params["timespan"])
+	}
+
+	return nil
+}
+
+func validateRange(fromStr, toStr, timespan string) error {
+	from, err := parseDate("from", fromStr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	to, err := parseDate("to", toStr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if to.Before(from) {
+		return errors.BadRequestf("from must not be after to")
+	}
+
+	maxRange, ok := maxRanges[timespan]
+	if !ok {
+		maxRange = defaultMaxRange
+	}
+
+	if to.Sub(from) > maxRange {
+		return errors.BadRequestf("%s ranges may span at most %d days", timespan, int(maxRange.Hours()/24))
+	}
+
+	return nil
+}
+
+func parseDate(name, value string) (time.Time, error) {
+	date, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return date, errors.BadRequestf("%s must be a YYYY-MM-DD date", name)
+	}
+
+	if date.After(time.Now()) {
+		return date, errors.BadRequestf("%s must not be in the future", name)
+	}
+
+	return date, nil
+}
+
+func isTimespan(timespan string) bool {
+	for _, allowed := range timespans {
+		if timespan == allowed {
+			return true
+		}
+	}
+
+	return false
+}
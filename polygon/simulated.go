@@ -0,0 +1,453 @@
+package polygon
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	tradingDaysPerYear = 252
+	sessionMinutes     = 390
+	sessionOpenMinute  = 14*60 + 30 // 9:30 ET as UTC during standard time
+)
+
+// simulationStart is the first trading day of every simulated series, so a
+// ticker's price on a date never depends on the range that was requested.
+var simulationStart = time.Date(2000, time.January, 3, 0, 0, 0, 0, time.UTC)
+
+var simulatedTickers = []TickerDetails{
+	{Ticker: "AAPL", Name: "Apple Inc."},
+	{Ticker: "AMZN", Name: "Amazon.com, Inc."},
+	{Ticker: "GOOGL", Name: "Alphabet Inc. Class A"},
+	{Ticker: "META", Name: "Meta Platforms, Inc."},
+	{Ticker: "MSFT", Name: "Microsoft Corporation"},
+	{Ticker: "NFLX", Name: "Netflix, Inc."},
+	{Ticker: "NVDA", Name: "NVIDIA Corporation"},
+	{Ticker: "TSLA", Name: "Tesla, Inc."},
+}
+
+// SimulatedClient answers the allowlisted Polygon routes with generated data
+// instead of calling Polygon. Each ticker follows its own geometric Brownian
+// motion seeded from the symbol, so the same request always returns the same
+// prices.
+type SimulatedClient struct {
+	now func() time.Time
+}
+
+type simulatedDay struct {
+	date  time.Time
+	open  float64
+	high  float64
+	low   float64
+	close float64
+	vol   float64
+}
+
+type tickerParams struct {
+	seed   int64
+	start  float64
+	drift  float64
+	sigma  float64
+	volume float64
+}
+
+func NewSimulatedClient() *SimulatedClient {
+	return &SimulatedClient{
+		now: time.Now,
+	}
+}
+
+func (s *SimulatedClient) GetPolygonPath(path string) (*Response, error) {
+	routePath := path
+	query := url.Values{}
+
+	if idx := strings.Index(path, "?"); idx > -1 {
+		routePath = path[:idx]
+		query, _ = url.ParseQuery(path[idx+1:])
+	}
+
+	route, params := findRoute(routePath)
+	if route == nil {
+		return simulatedResponse(http.StatusNotFound, map[string]string{"status": "NOT_FOUND"})
+	}
+
+	switch route.Path {
+	case "v2/aggs/ticker/{ticker}/range/{multiplier}/{timespan}/{from}/{to}":
+		return s.aggregates(params, query)
+	case "v2/aggs/ticker/{ticker}/prev":
+		return s.previousClose(params["ticker"])
+	case "v1/open-close/{ticker}/{date}":
+		return s.openClose(params["ticker"], params["date"])
+	case "v2/aggs/grouped/locale/us/market/stocks/{date}":
+		return s.grouped(params["date"])
+	case "v3/reference/tickers/{ticker}":
+		return s.tickerDetails(params["ticker"])
+	case "v3/reference/tickers":
+		return s.searchTickers(query)
+	case "v1/marketstatus/now":
+		return s.marketStatus()
+	}
+
+	return simulatedResponse(http.StatusNotFound, map[string]string{"status": "NOT_FOUND"})
+}
+
+func (s *SimulatedClient) aggregates(params map[string]string, query url.Values) (*Response, error) {
+	ticker := params["ticker"]
+	timespan := params["timespan"]
+	multiplier, _ := strconv.Atoi(params["multiplier"])
+	from, _ := time.Parse(dateFormat, params["from"])
+	to, _ := time.Parse(dateFormat, params["to"])
+
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	days := simulateDays(ticker, to)
+
+	var bars []aggBar
+	for _, day := range days {
+		if day.date.Before(from) {
+			continue
+		}
+
+		switch timespan {
+		case "minute", "hour":
+			bars = append(bars, intradayBars(ticker, day, timespan)...)
+		default:
+			bars = append(bars, day.toAggBar(ticker))
+		}
+	}
+
+	bars = groupBars(bars, timespan, multiplier)
+
+	if query.Get("sort") == "desc" {
+		sort.Slice(bars, func(i, j int) bool {
+			return bars[i].Timestamp > bars[j].Timestamp
+		})
+	}
+
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(bars) {
+		bars = bars[:limit]
+	}
+
+	return simulatedAggs(ticker, bars)
+}
+
+func (s *SimulatedClient) previousClose(ticker string) (*Response, error) {
+	days := simulateDays(ticker, s.now().UTC().AddDate(0, 0, -1))
+	if len(days) == 0 {
+		return simulatedAggs(ticker, nil)
+	}
+
+	return simulatedAggs(ticker, []aggBar{days[len(days)-1].toAggBar(ticker)})
+}
+
+func (s *SimulatedClient) openClose(ticker, dateStr string) (*Response, error) {
+	date, _ := time.Parse(dateFormat, dateStr)
+
+	days := simulateDays(ticker, date)
+	if len(days) == 0 || !days[len(days)-1].date.Equal(date) {
+		return simulatedResponse(http.StatusNotFound, map[string]string{"status": "NOT_FOUND", "message": "Data not found."})
+	}
+
+	day := days[len(days)-1]
+
+	return simulatedResponse(http.StatusOK, map[string]interface{}{
+		"status": "OK",
+		"from":   dateStr,
+		"symbol": ticker,
+		"open":   day.open,
+		"high":   day.high,
+		"low":    day.low,
+		"close":  day.close,
+		"volume": day.vol,
+	})
+}
+
+func (s *SimulatedClient) grouped(dateStr string) (*Response, error) {
+	date, _ := time.Parse(dateFormat, dateStr)
+
+	var bars []aggBar
+	for _, details := range simulatedTickers {
+		days := simulateDays(details.Ticker, date)
+		if len(days) > 0 && days[len(days)-1].date.Equal(date) {
+			bars = append(bars, days[len(days)-1].toAggBar(details.Ticker))
+		}
+	}
+
+	return simulatedAggs("", bars)
+}
+
+func (s *SimulatedClient) tickerDetails(ticker string) (*Response, error) {
+	return simulatedResponse(http.StatusOK, map[string]interface{}{
+		"status":  "OK",
+		"results": simulatedTickerResult(ticker),
+	})
+}
+
+func (s *SimulatedClient) searchTickers(query url.Values) (*Response, error) {
+	search := strings.ToLower(query.Get("search"))
+
+	results := []tickerResult{}
+	for _, details := range simulatedTickers {
+		if strings.Contains(strings.ToLower(details.Ticker), search) || strings.Contains(strings.ToLower(details.Name), search) {
+			results = append(results, simulatedTickerResult(details.Ticker))
+		}
+	}
+
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit < len(results) {
+		results = results[:limit]
+	}
+
+	return simulatedResponse(http.StatusOK, map[string]interface{}{
+		"status":  "OK",
+		"count":   len(results),
+		"results": results,
+	})
+}
+
+func (s *SimulatedClient) marketStatus() (*Response, error) {
+	now := s.now().UTC()
+	minute := now.Hour()*60 + now.Minute()
+
+	market := "closed"
+	if isTradingDay(now) && minute >= sessionOpenMinute && minute < sessionOpenMinute+sessionMinutes {
+		market = "open"
+	}
+
+	return simulatedResponse(http.StatusOK, map[string]interface{}{
+		"market":     market,
+		"serverTime": now.Format(time.RFC3339),
+		"exchanges": map[string]string{
+			"nasdaq": market,
+			"nyse":   market,
+		},
+	})
+}
+
+// simulateDays walks the ticker's daily series from simulationStart through
+// the last trading day on or before to.
+func simulateDays(ticker string, to time.Time) []simulatedDay {
+	params := paramsFor(ticker)
+	rng := rand.New(rand.NewSource(params.seed))
+	dt := 1.0 / tradingDaysPerYear
+
+	var days []simulatedDay
+
+	price := params.start
+	for date := simulationStart; !date.After(to); date = date.AddDate(0, 0, 1) {
+		if !isTradingDay(date) {
+			continue
+		}
+
+		open := price * math.Exp(rng.NormFloat64()*params.sigma*math.Sqrt(dt)*0.2)
+		closePrice := open * math.Exp((params.drift-params.sigma*params.sigma/2)*dt+params.sigma*math.Sqrt(dt)*rng.NormFloat64())
+		wick := params.sigma * math.Sqrt(dt)
+
+		days = append(days, simulatedDay{
+			date:  date,
+			open:  round2(open),
+			high:  round2(math.Max(open, closePrice) * (1 + math.Abs(rng.NormFloat64())*wick*0.5)),
+			low:   round2(math.Min(open, closePrice) * (1 - math.Abs(rng.NormFloat64())*wick*0.5)),
+			close: round2(closePrice),
+			vol:   math.Round(params.volume * math.Exp(rng.NormFloat64()*0.3)),
+		})
+
+		price = closePrice
+	}
+
+	return days
+}
+
+// intradayBars bridges the day's open to its close with a per-day seeded
+// random walk kept inside the day's high and low.
+func intradayBars(ticker string, day simulatedDay, timespan string) []aggBar {
+	rng := rand.New(rand.NewSource(paramsFor(ticker).seed ^ day.date.Unix()))
+
+	step := 1
+	if timespan == "hour" {
+		step = 60
+	}
+
+	bars := make([]aggBar, 0, sessionMinutes/step+1)
+	price := day.open
+
+	for minute := 0; minute < sessionMinutes; minute += step {
+		remaining := float64(sessionMinutes - minute)
+		steps := math.Min(float64(step), remaining)
+
+		target := price + (day.close-price)*steps/remaining
+		noise := (day.high - day.low) * 0.05 * rng.NormFloat64()
+		next := math.Min(day.high, math.Max(day.low, target+noise))
+
+		if minute+step >= sessionMinutes {
+			next = day.close
+		}
+
+		bars = append(bars, aggBar{
+			Ticker:       ticker,
+			Open:         round2(price),
+			High:         round2(math.Max(price, next)),
+			Low:          round2(math.Min(price, next)),
+			Close:        round2(next),
+			Volume:       math.Round(day.vol * steps / sessionMinutes),
+			VWAP:         round2((price + next) / 2),
+			Transactions: int64(day.vol * steps / sessionMinutes / 100),
+			Timestamp:    day.date.Add(time.Duration(sessionOpenMinute+minute)*time.Minute).UnixNano() / int64(time.Millisecond),
+		})
+
+		price = next
+	}
+
+	return bars
+}
+
+// groupBars merges bars into calendar buckets of multiplier timespans.
+func groupBars(bars []aggBar, timespan string, multiplier int) []aggBar {
+	var grouped []aggBar
+
+	lastBucket := int64(-1)
+	for _, bar := range bars {
+		bucket := bucketFor(time.Unix(0, bar.Timestamp*int64(time.Millisecond)).UTC(), timespan) / int64(multiplier)
+		if bucket != lastBucket || len(grouped) == 0 {
+			grouped = append(grouped, bar)
+			lastBucket = bucket
+
+			continue
+		}
+
+		current := &grouped[len(grouped)-1]
+		current.High = math.Max(current.High, bar.High)
+		current.Low = math.Min(current.Low, bar.Low)
+		current.Close = bar.Close
+		current.VWAP = round2((current.VWAP*current.Volume + bar.VWAP*bar.Volume) / math.Max(1, current.Volume+bar.Volume))
+		current.Volume += bar.Volume
+		current.Transactions += bar.Transactions
+	}
+
+	return grouped
+}
+
+func bucketFor(t time.Time, timespan string) int64 {
+	switch timespan {
+	case "minute":
+		return t.Unix() / 60
+	case "hour":
+		return t.Unix() / 3600
+	case "week":
+		return int64(t.Sub(simulationStart).Hours() / (24 * 7))
+	case "month":
+		return int64(t.Year()*12 + int(t.Month()))
+	case "quarter":
+		return int64(t.Year()*4 + (int(t.Month())-1)/3)
+	case "year":
+		return int64(t.Year())
+	}
+
+	return int64(t.Sub(simulationStart).Hours() / 24)
+}
+
+func paramsFor(ticker string) tickerParams {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(ticker))
+	seed := int64(hash.Sum64() & math.MaxInt64)
+
+	rng := rand.New(rand.NewSource(seed))
+
+	return tickerParams{
+		seed:   seed,
+		start:  20 + rng.Float64()*180,
+		drift:  0.03 + rng.Float64()*0.12,
+		sigma:  0.15 + rng.Float64()*0.3,
+		volume: 1e6 + rng.Float64()*5e7,
+	}
+}
+
+func simulatedTickerResult(ticker string) tickerResult {
+	name := fmt.Sprintf("%s Simulated Corp.", ticker)
+	for _, details := range simulatedTickers {
+		if details.Ticker == ticker {
+			name = details.Name
+		}
+	}
+
+	return tickerResult{
+		Ticker:          ticker,
+		Name:            name,
+		Market:          "stocks",
+		Locale:          "us",
+		PrimaryExchange: "XNAS",
+		Type:            "CS",
+		Active:          true,
+		CurrencyName:    "usd",
+		Description:     "Simulated company for offline stock lessons.",
+		ListDate:        simulationStart.Format(dateFormat),
+	}
+}
+
+func (d simulatedDay) toAggBar(ticker string) aggBar {
+	return aggBar{
+		Ticker:       ticker,
+		Open:         d.open,
+		High:         d.high,
+		Low:          d.low,
+		Close:        d.close,
+		Volume:       d.vol,
+		VWAP:         round2((d.high + d.low + d.close) / 3),
+		Timestamp:    d.date.UnixNano() / int64(time.Millisecond),
+		Transactions: int64(d.vol / 100),
+	}
+}
+
+func simulatedAggs(ticker string, bars []aggBar) (*Response, error) {
+	if bars == nil {
+		bars = []aggBar{}
+	}
+
+	return simulatedResponse(http.StatusOK, map[string]interface{}{
+		"ticker":       ticker,
+		"status":       "OK",
+		"adjusted":     true,
+		"queryCount":   len(bars),
+		"resultsCount": len(bars),
+		"results":      bars,
+		"request_id":   "simulated",
+	})
+}
+
+func simulatedResponse(statusCode int, payload interface{}) (*Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		StatusCode: statusCode,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       body,
+	}, nil
+}
+
+// isTradingDay reports whether NYSE is open on date: a weekday that is not
+// one of its holidays or unscheduled closures.
+func isTradingDay(date time.Time) bool {
+	weekday := date.Weekday()
+	if weekday == time.Saturday || weekday == time.Sunday {
+		return false
+	}
+
+	return !isMarketHoliday(date)
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,114 @@
+package polygon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const simulatedRangePath = "v2/aggs/ticker/AAPL/range/1/day/2024-01-01/2024-03-14?adjusted=true&sort=asc"
+
+func simulatedBars(t *testing.T, client *SimulatedClient, path string) []Bar {
+	t.Helper()
+
+	res, err := client.GetPolygonPath(path)
+	if err != nil {
+		t.Fatalf("GetPolygonPath(%q) returned %v", path, err)
+	}
+
+	var aggs aggsRes
+
+	err = json.Unmarshal(res.Body, &aggs)
+	if err != nil {
+		t.Fatalf("decoding bars for %q returned %v", path, err)
+	}
+
+	if len(aggs.Results) == 0 {
+		t.Fatalf("GetPolygonPath(%q) returned no bars", path)
+	}
+
+	bars := make([]Bar, len(aggs.Results))
+	for i, bar := range aggs.Results {
+		bars[i] = bar.toBar()
+	}
+
+	return bars
+}
+
+func TestSimulatedIsDeterministic(t *testing.T) {
+	first := simulatedBars(t, NewSimulatedClient(), simulatedRangePath)
+	second := simulatedBars(t, NewSimulatedClient(), simulatedRangePath)
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same ticker and range gave different bars")
+	}
+
+	other := simulatedBars(t, NewSimulatedClient(), "v2/aggs/ticker/MSFT/range/1/day/2024-01-01/2024-03-14")
+	if reflect.DeepEqual(first[0], other[0]) {
+		t.Errorf("AAPL and MSFT gave the same bars")
+	}
+}
+
+func TestSimulatedPrevMatchesLastAggregate(t *testing.T) {
+	client := NewSimulatedClient()
+	client.now = func() time.Time {
+		return time.Date(2024, time.March, 15, 12, 0, 0, 0, time.UTC)
+	}
+
+	bars := simulatedBars(t, client, simulatedRangePath)
+	prev := simulatedBars(t, client, "v2/aggs/ticker/AAPL/prev")
+
+	if len(prev) != 1 {
+		t.Fatalf("prev returned %d bars, want 1", len(prev))
+	}
+
+	if last := bars[len(bars)-1]; !reflect.DeepEqual(prev[0], last) {
+		t.Errorf("prev = %+v, want last aggregate %+v", prev[0], last)
+	}
+}
+
+func TestMarketHolidays(t *testing.T) {
+	tests := []struct {
+		date    string
+		trading bool
+	}{
+		{"2024-01-01", false},
+		{"2024-01-15", false},
+		{"2024-02-19", false},
+		{"2024-03-29", false},
+		{"2024-05-27", false},
+		{"2024-06-19", false},
+		{"2024-07-04", false},
+		{"2024-09-02", false},
+		{"2024-11-28", false},
+		{"2024-12-25", false},
+		{"2024-12-24", true},
+		{"2021-06-18", true},
+		{"2021-07-05", false},
+		{"2021-12-24", false},
+		{"2021-12-31", true},
+		{"2022-06-20", false},
+		{"2012-10-29", false},
+		{"2024-03-14", true},
+		{"2024-03-16", false},
+	}
+
+	for _, test := range tests {
+		date, _ := time.Parse(dateFormat, test.date)
+		if got := isTradingDay(date); got != test.trading {
+			t.Errorf("isTradingDay(%s) = %v, want %v", test.date, got, test.trading)
+		}
+	}
+
+	bars := simulatedBars(t, NewSimulatedClient(), "v2/aggs/ticker/AAPL/range/1/day/2024-12-23/2024-12-27")
+	for _, bar := range bars {
+		if bar.Time[:10] == "2024-12-25" {
+			t.Errorf("simulated a bar on Christmas")
+		}
+	}
+
+	if len(bars) != 4 {
+		t.Errorf("got %d bars for Christmas week, want 4", len(bars))
+	}
+}
@@ -0,0 +1,334 @@
+package polygon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 100
+)
+
+type pathClient interface {
+	GetPolygonPath(path string) (*Response, error)
+}
+
+// UpstreamStatusError is returned by StockService when Polygon answers with a
+// status other than 200.
+type UpstreamStatusError struct {
+	StatusCode int
+	Header     http.Header
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("polygon returned status %d", e.StatusCode)
+}
+
+type aggsRes struct {
+	Ticker  string   `json:"ticker"`
+	Status  string   `json:"status"`
+	Results []aggBar `json:"results"`
+}
+
+type aggBar struct {
+	Ticker       string  `json:"T"`
+	Open         float64 `json:"o"`
+	High         float64 `json:"h"`
+	Low          float64 `json:"l"`
+	Close        float64 `json:"c"`
+	Volume       float64 `json:"v"`
+	VWAP         float64 `json:"vw"`
+	Timestamp    int64   `json:"t"`
+	Transactions int64   `json:"n"`
+}
+
+type tickersRes struct {
+	Status  string         `json:"status"`
+	Results []tickerResult `json:"results"`
+}
+
+type tickerRes struct {
+	Status  string       `json:"status"`
+	Results tickerResult `json:"results"`
+}
+
+type tickerResult struct {
+	Ticker          string  `json:"ticker"`
+	Name            string  `json:"name"`
+	Market          string  `json:"market"`
+	Locale          string  `json:"locale"`
+	PrimaryExchange string  `json:"primary_exchange"`
+	Type            string  `json:"type"`
+	Active          bool    `json:"active"`
+	CurrencyName    string  `json:"currency_name"`
+	Description     string  `json:"description"`
+	HomepageURL     string  `json:"homepage_url"`
+	MarketCap       float64 `json:"market_cap"`
+	TotalEmployees  int64   `json:"total_employees"`
+	ListDate        string  `json:"list_date"`
+}
+
+// Bar is one OHLCV aggregate.
+type Bar struct {
+	Time         string  `json:"time"`
+	Open         float64 `json:"open"`
+	High         float64 `json:"high"`
+	Low          float64 `json:"low"`
+	Close        float64 `json:"close"`
+	Volume       float64 `json:"volume"`
+	VWAP         float64 `json:"vwap"`
+	Transactions int64   `json:"transactions"`
+}
+
+type Quote struct {
+	Ticker string `json:"ticker"`
+	Bar
+	Change        float64 `json:"change"`
+	ChangePercent float64 `json:"changePercent"`
+}
+
+type Aggregates struct {
+	Ticker     string `json:"ticker"`
+	Multiplier int    `json:"multiplier"`
+	Timespan   string `json:"timespan"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Bars       []Bar  `json:"bars"`
+}
+
+// TickerBar is a Bar tagged with its ticker, as returned by grouped daily
+// aggregates.
+type TickerBar struct {
+	Ticker string `json:"ticker"`
+	Bar
+}
+
+type AggregatesQuery struct {
+	Ticker     string
+	Multiplier int
+	Timespan   string
+	From       string
+	To         string
+}
+
+type TickerDetails struct {
+	Ticker         string  `json:"ticker"`
+	Name           string  `json:"name"`
+	Market         string  `json:"market"`
+	Locale         string  `json:"locale"`
+	Exchange       string  `json:"exchange"`
+	Type           string  `json:"type"`
+	Active         bool    `json:"active"`
+	Currency       string  `json:"currency"`
+	Description    string  `json:"description,omitempty"`
+	HomepageURL    string  `json:"homepageUrl,omitempty"`
+	MarketCap      float64 `json:"marketCap,omitempty"`
+	TotalEmployees int64   `json:"totalEmployees,omitempty"`
+	ListDate       string  `json:"listDate,omitempty"`
+}
+
+// StockService turns raw Polygon responses into stable DTOs so student apps
+// don't break when Polygon changes its schema.
+type StockService struct {
+	polygon pathClient
+}
+
+func NewStockService(polygon pathClient) *StockService {
+	return &StockService{
+		polygon: polygon,
+	}
+}
+
+// GetQuote returns the previous trading day's bar for ticker.
+func (s *StockService) GetQuote(ticker string) (Quote, error) {
+	ticker = strings.ToUpper(ticker)
+
+	res := aggsRes{}
+	err := s.get(fmt.Sprintf("v2/aggs/ticker/%s/prev", ticker), url.Values{"adjusted": {"true"}}, &res)
+	if err != nil {
+		return Quote{}, errors.Trace(err)
+	}
+
+	if len(res.Results) == 0 {
+		return Quote{}, errors.NotFoundf("quote for %s", ticker)
+	}
+
+	bar := res.Results[0].toBar()
+	quote := Quote{
+		Ticker: ticker,
+		Bar:    bar,
+		Change: bar.Close - bar.Open,
+	}
+
+	if bar.Open != 0 {
+		quote.ChangePercent = quote.Change / bar.Open * 100
+	}
+
+	return quote, nil
+}
+
+func (s *StockService) GetAggregates(query AggregatesQuery) (Aggregates, error) {
+	query.Ticker = strings.ToUpper(query.Ticker)
+
+	path := fmt.Sprintf("v2/aggs/ticker/%s/range/%d/%s/%s/%s",
+		query.Ticker, query.Multiplier, query.Timespan, query.From, query.To)
+
+	res := aggsRes{}
+	err := s.get(path, url.Values{"adjusted": {"true"}, "sort": {"asc"}}, &res)
+	if err != nil {
+		return Aggregates{}, errors.Trace(err)
+	}
+
+	aggregates := Aggregates{
+		Ticker:     query.Ticker,
+		Multiplier: query.Multiplier,
+		Timespan:   query.Timespan,
+		From:       query.From,
+		To:         query.To,
+		Bars:       make([]Bar, 0, len(res.Results)),
+	}
+
+	for _, result := range res.Results {
+		aggregates.Bars = append(aggregates.Bars, result.toBar())
+	}
+
+	return aggregates, nil
+}
+
+func (s *StockService) GetTickerDetails(ticker string) (TickerDetails, error) {
+	ticker = strings.ToUpper(ticker)
+
+	res := tickerRes{}
+	err := s.get(fmt.Sprintf("v3/reference/tickers/%s", ticker), nil, &res)
+	if err != nil {
+		return TickerDetails{}, errors.Trace(err)
+	}
+
+	if res.Results.Ticker == "" {
+		return TickerDetails{}, errors.NotFoundf("ticker %s", ticker)
+	}
+
+	return res.Results.toDetails(), nil
+}
+
+// SearchTickers finds active stocks whose symbol or name matches query.
+func (s *StockService) SearchTickers(query string, limit int) ([]TickerDetails, error) {
+	params := url.Values{
+		"search": {query},
+		"market": {"stocks"},
+		"active": {"true"},
+		"limit":  {strconv.Itoa(limit)},
+	}
+
+	res := tickersRes{}
+	err := s.get("v3/reference/tickers", params, &res)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	tickers := make([]TickerDetails, 0, len(res.Results))
+	for _, result := range res.Results {
+		details := result.toDetails()
+		details.Description = ""
+		tickers = append(tickers, details)
+	}
+
+	return tickers, nil
+}
+
+// get validates path and query against the allowlisted routes before
+// fetching and decoding the response.
+func (s *StockService) get(path string, query url.Values, target interface{}) error {
+	_, err := MatchRoute(path, query)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if len(query) > 0 {
+		path = fmt.Sprintf("%s?%s", path, query.Encode())
+	}
+
+	res, err := s.polygon.GetPolygonPath(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return errors.NotFoundf("polygon path %s", path)
+	default:
+		return &UpstreamStatusError{StatusCode: res.StatusCode, Header: res.Header}
+	}
+
+	err = json.Unmarshal(res.Body, target)
+
+	return errors.Annotatef(err, "could not unmarshal polygon response for %s", path)
+}
+
+// ParseAggregateBars decodes a raw Polygon aggregates body, from the range,
+// previous close or grouped daily routes, into bars.
+func ParseAggregateBars(body []byte) ([]TickerBar, error) {
+	res := aggsRes{}
+
+	err := json.Unmarshal(body, &res)
+	if err != nil {
+		return nil, errors.Annotate(err, "could not unmarshal polygon aggregates")
+	}
+
+	bars := make([]TickerBar, 0, len(res.Results))
+	for _, result := range res.Results {
+		ticker := result.Ticker
+		if ticker == "" {
+			ticker = res.Ticker
+		}
+
+		bars = append(bars, TickerBar{Ticker: ticker, Bar: result.toBar()})
+	}
+
+	return bars, nil
+}
+
+// IsAggregatesRoute reports whether route returns aggregate bars.
+func IsAggregatesRoute(route *Route) bool {
+	return route != nil && strings.HasPrefix(route.Path, "v2/aggs/")
+}
+
+func (b aggBar) toBar() Bar {
+	return Bar{
+		Time:         time.Unix(0, b.Timestamp*int64(time.Millisecond)).UTC().Format(time.RFC3339),
+		Open:         b.Open,
+		High:         b.High,
+		Low:          b.Low,
+		Close:        b.Close,
+		Volume:       b.Volume,
+		VWAP:         b.VWAP,
+		Transactions: b.Transactions,
+	}
+}
+
+func (t *tickerResult) toDetails() TickerDetails {
+	return TickerDetails{
+		Ticker:         t.Ticker,
+		Name:           t.Name,
+		Market:         t.Market,
+		Locale:         t.Locale,
+		Exchange:       t.PrimaryExchange,
+		Type:           t.Type,
+		Active:         t.Active,
+		Currency:       t.CurrencyName,
+		Description:    t.Description,
+		HomepageURL:    t.HomepageURL,
+		MarketCap:      t.MarketCap,
+		TotalEmployees: t.TotalEmployees,
+		ListDate:       t.ListDate,
+	}
+}
@@ -0,0 +1,386 @@
+package projects
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	collectionKeyFormat = "_collections:%s:%s"
+	// itemTimeFormat has fixed width so timestamps sort as strings.
+	itemTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+	// MaxItemBytes caps the JSON body of one collection item.
+	MaxItemBytes = 64 << 10
+
+	DefaultItemSort = "createdAt"
+
+	idField        = "id"
+	itemCreatedAt  = "createdAt"
+	itemUpdatedAt  = "updatedAt"
+	descendingSort = "-"
+)
+
+var (
+	collectionNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	sortFieldRegex      = regexp.MustCompile(`^-?[A-Za-z0-9_]{1,64}$`)
+)
+
+// Item is one JSON object in a collection. The id, createdAt and updatedAt
+// fields are always set by the server.
+type Item map[string]interface{}
+
+type ItemList struct {
+	Group      string `json:"group"`
+	Collection string `json:"collection"`
+	Sort       string `json:"sort"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Items      []Item `json:"items"`
+}
+
+type ItemQuery struct {
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+func IsValidCollectionName(name string) bool {
+	return collectionNameRegex.MatchString(name)
+}
+
+// ParseItem decodes a JSON object body into an Item, keeping numbers as
+// written.
+func ParseItem(body []byte) (Item, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var item Item
+
+	err := decoder.Decode(&item)
+	if err != nil || item == nil {
+		return nil, errors.NotValidf("item, expected a JSON object")
+	}
+
+	if decoder.More() {
+		return nil, errors.NotValidf("item, expected a single JSON object")
+	}
+
+	return item, nil
+}
+
+// AddItem stores item under a new id in the collection, creating the
+// collection if needed.
+func (p *ProjectStoreService) AddItem(groupName, collection string, item Item) (Item, error) {
+	now := time.Now().UTC().Format(itemTimeFormat)
+
+	item = withoutServerFields(item)
+	item[itemCreatedAt] = now
+	item[itemUpdatedAt] = now
+
+	key := collectionKey(groupName, collection)
+
+	for {
+		item[idField] = uuid.New().String()
+
+		value, err := json.Marshal(item)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		set, err := p.keyValRepo.SetHashFieldNX(key, item[idField].(string), string(value))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if set {
+			return item, nil
+		}
+	}
+}
+
+// ListItems returns a page of the collection ordered by query.Sort, a field
+// name prefixed with - for descending order. Items missing the field come last.
+func (p *ProjectStoreService) ListItems(groupName, collection string, query ItemQuery) (ItemList, error) {
+	if query.Sort == "" {
+		query.Sort = DefaultItemSort
+	}
+
+	if !sortFieldRegex.MatchString(query.Sort) {
+		return ItemList{}, errors.NotValidf("sort %q", query.Sort)
+	}
+
+	key := collectionKey(groupName, collection)
+
+	values, err := p.keyValRepo.GetHash(key)
+	if err != nil {
+		return ItemList{}, errors.Trace(err)
+	}
+
+	items := make([]Item, 0, len(values))
+	for id, value := range values {
+		item, err := ParseItem([]byte(value))
+		if err != nil {
+			log.Errorf("Could not decode collection item: %s %s\n%+v\n", key, id, err)
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	sortItems(items, query.Sort)
+
+	list := ItemList{
+		Group:      groupName,
+		Collection: collection,
+		Sort:       query.Sort,
+		Total:      len(items),
+		Limit:      query.Limit,
+		Offset:     query.Offset,
+		Items:      []Item{},
+	}
+
+	if query.Offset < len(items) {
+		end := query.Offset + query.Limit
+		if end > len(items) {
+			end = len(items)
+		}
+
+		list.Items = items[query.Offset:end]
+	}
+
+	return list, nil
+}
+
+func (p *ProjectStoreService) GetItem(groupName, collection, id string) (Item, error) {
+	if !isValidItemID(id) {
+		return nil, errors.NotFoundf("item %s", id)
+	}
+
+	value, err := p.keyValRepo.GetHashField(collectionKey(groupName, collection), id)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	item, err := ParseItem([]byte(value))
+
+	return item, errors.Trace(err)
+}
+
+// ReplaceItem replaces every field of an existing item, keeping its id and
+// createdAt.
+func (p *ProjectStoreService) ReplaceItem(groupName, collection, id string, item Item) (Item, error) {
+	return p.updateItem(groupName, collection, id, func(current Item) Item {
+		replaced := withoutServerFields(item)
+		replaced[itemCreatedAt] = current[itemCreatedAt]
+
+		return replaced
+	})
+}
+
+// PatchItem applies patch to an existing item as a JSON merge patch (RFC 7396):
+// objects are merged recursively and null removes a field.
+func (p *ProjectStoreService) PatchItem(groupName, collection, id string, patch Item) (Item, error) {
+	return p.updateItem(groupName, collection, id, func(current Item) Item {
+		mergePatch(current, withoutServerFields(patch))
+
+		return current
+	})
+}
+
+func (p *ProjectStoreService) DeleteItem(groupName, collection, id string) error {
+	if !isValidItemID(id) {
+		return errors.NotFoundf("item %s", id)
+	}
+
+	deleted, err := p.keyValRepo.DeleteHashFields(collectionKey(groupName, collection), id)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	if deleted == 0 {
+		return errors.NotFoundf("item %s", id)
+	}
+
+	return nil
+}
+
+// updateItem rewrites an existing item atomically, so concurrent writers
+// never lose each other's changes.
+func (p *ProjectStoreService) updateItem(groupName, collection, id string, update func(current Item) Item) (Item, error) {
+	if !isValidItemID(id) {
+		return nil, errors.NotFoundf("item %s", id)
+	}
+
+	var updated Item
+
+	_, err := p.keyValRepo.UpdateHashField(collectionKey(groupName, collection), id, func(value string) (string, error) {
+		current, err := ParseItem([]byte(value))
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		updated = update(current)
+		updated[idField] = id
+		updated[itemUpdatedAt] = time.Now().UTC().Format(itemTimeFormat)
+
+		encoded, err := json.Marshal(updated)
+
+		return string(encoded), errors.Trace(err)
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return updated, nil
+}
+
+// groupCollections returns the keys of the group's collections, leaving out
+// those of groups whose names start with "{groupName}:", which older versions
+// allowed.
+func (p *ProjectStoreService) groupCollections(groupName string) ([]string, error) {
+	prefix := collectionKey(groupName, "")
+
+	scanned, err := p.keyValRepo.ScanKeys(escapeGlob(prefix) + "*")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	keys := make([]string, 0, len(scanned))
+	for _, key := range scanned {
+		if IsValidCollectionName(strings.TrimPrefix(key, prefix)) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func collectionKey(groupName, collection string) string {
+	return fmt.Sprintf(collectionKeyFormat, groupName, collection)
+}
+
+func isValidItemID(id string) bool {
+	_, err := uuid.Parse(id)
+
+	return err == nil
+}
+
+func withoutServerFields(item Item) Item {
+	cleaned := make(Item, len(item)+3)
+	for field, value := range item {
+		switch field {
+		case idField, itemCreatedAt, itemUpdatedAt:
+			continue
+		}
+
+		cleaned[field] = value
+	}
+
+	return cleaned
+}
+
+func mergePatch(target, patch map[string]interface{}) {
+	for field, value := range patch {
+		if value == nil {
+			delete(target, field)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]interface{})
+		if !ok {
+			target[field] = value
+			continue
+		}
+
+		targetObject, ok := target[field].(map[string]interface{})
+		if !ok {
+			targetObject = map[string]interface{}{}
+		}
+
+		mergePatch(targetObject, patchObject)
+		target[field] = targetObject
+	}
+}
+
+// sortItems orders items by field, breaking ties by id so pages are stable.
+func sortItems(items []Item, field string) {
+	descending := strings.HasPrefix(field, descendingSort)
+	field = strings.TrimPrefix(field, descendingSort)
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, aOK := items[i][field]
+		b, bOK := items[j][field]
+
+		if aOK != bOK {
+			return aOK
+		}
+
+		if cmp := compareValues(a, b); cmp != 0 {
+			if descending {
+				return cmp > 0
+			}
+
+			return cmp < 0
+		}
+
+		return fmt.Sprint(items[i][idField]) < fmt.Sprint(items[j][idField])
+	})
+}
+
+// compareValues orders numbers before strings, booleans and other values,
+// comparing numbers and strings by value.
+func compareValues(a, b interface{}) int {
+	aRank, bRank := valueRank(a), valueRank(b)
+	if aRank != bRank {
+		return aRank - bRank
+	}
+
+	switch aValue := a.(type) {
+	case json.Number:
+		aFloat, _ := aValue.Float64()
+		bFloat, _ := b.(json.Number).Float64()
+
+		switch {
+		case aFloat < bFloat:
+			return -1
+		case aFloat > bFloat:
+			return 1
+		}
+	case string:
+		return strings.Compare(aValue, b.(string))
+	case bool:
+		bValue := b.(bool)
+		if aValue != bValue {
+			if bValue {
+				return -1
+			}
+
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func valueRank(value interface{}) int {
+	switch value.(type) {
+	case json.Number:
+		return 0
+	case string:
+		return 1
+	case bool:
+		return 2
+	default:
+		return 3
+	}
+}
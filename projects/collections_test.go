@@ -0,0 +1,343 @@
+package projects
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/juju/errors"
+)
+
+// memoryRepo is an in-memory keyValRepository with Redis glob matching.
+type memoryRepo struct {
+	values map[string]string
+	hashes map[string]map[string]string
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		values: make(map[string]string),
+		hashes: make(map[string]map[string]string),
+	}
+}
+
+func (m *memoryRepo) SetKeyValue(key, value string) error {
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryRepo) GetValue(key string) (string, error) {
+	return m.values[key], nil
+}
+
+func (m *memoryRepo) ScanKeys(pattern string) ([]string, error) {
+	var keys []string
+
+	for _, store := range []map[string]bool{m.keys(m.values), m.hashKeys()} {
+		for key := range store {
+			if matched, _ := path.Match(pattern, key); matched {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+func (m *memoryRepo) DeleteKeys(keys ...string) (int64, error) {
+	var deleted int64
+
+	for _, key := range keys {
+		if _, ok := m.values[key]; ok {
+			delete(m.values, key)
+			deleted++
+		}
+
+		if _, ok := m.hashes[key]; ok {
+			delete(m.hashes, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (m *memoryRepo) ValueSizes(keys []string) ([]int64, error) {
+	sizes := make([]int64, len(keys))
+	for i, key := range keys {
+		sizes[i] = int64(len(m.values[key]))
+	}
+
+	return sizes, nil
+}
+
+func (m *memoryRepo) SetHashFields(key string, fields, onlyNew map[string]string) error {
+	hash := m.hash(key)
+
+	for field, value := range onlyNew {
+		if _, ok := hash[field]; !ok {
+			hash[field] = value
+		}
+	}
+
+	for field, value := range fields {
+		hash[field] = value
+	}
+
+	return nil
+}
+
+func (m *memoryRepo) GetHash(key string) (map[string]string, error) {
+	return m.hashes[key], nil
+}
+
+func (m *memoryRepo) GetHashField(key, field string) (string, error) {
+	value, ok := m.hashes[key][field]
+	if !ok {
+		return "", errors.NotFoundf("hash field %s in %s", field, key)
+	}
+
+	return value, nil
+}
+
+func (m *memoryRepo) SetHashFieldNX(key, field, value string) (bool, error) {
+	hash := m.hash(key)
+	if _, ok := hash[field]; ok {
+		return false, nil
+	}
+
+	hash[field] = value
+
+	return true, nil
+}
+
+func (m *memoryRepo) UpdateHashField(key, field string, update func(current string) (string, error)) (string, error) {
+	current, err := m.GetHashField(key, field)
+	if err != nil {
+		return "", err
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return "", err
+	}
+
+	m.hashes[key][field] = updated
+
+	return updated, nil
+}
+
+func (m *memoryRepo) DeleteHashFields(key string, fields ...string) (int64, error) {
+	var deleted int64
+
+	for _, field := range fields {
+		if _, ok := m.hashes[key][field]; ok {
+			delete(m.hashes[key], field)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (m *memoryRepo) hash(key string) map[string]string {
+	if m.hashes[key] == nil {
+		m.hashes[key] = make(map[string]string)
+	}
+
+	return m.hashes[key]
+}
+
+func (m *memoryRepo) keys(values map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(values))
+	for key := range values {
+		keys[key] = true
+	}
+
+	return keys
+}
+
+func (m *memoryRepo) hashKeys() map[string]bool {
+	keys := make(map[string]bool, len(m.hashes))
+	for key := range m.hashes {
+		keys[key] = true
+	}
+
+	return keys
+}
+
+func mustParseItem(t *testing.T, body string) Item {
+	t.Helper()
+
+	item, err := ParseItem([]byte(body))
+	if err != nil {
+		t.Fatalf("ParseItem(%s) returned %v", body, err)
+	}
+
+	return item
+}
+
+func TestParseItem(t *testing.T) {
+	for _, body := range []string{`[1]`, `"text"`, `null`, `{"a":1} {"b":2}`, `{`} {
+		if _, err := ParseItem([]byte(body)); err == nil {
+			t.Errorf("ParseItem(%s) accepted a non object", body)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"a":"b"}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		target := mustParseItem(t, test.target)
+		mergePatch(target, mustParseItem(t, test.patch))
+
+		if got, want := target, mustParseItem(t, test.want); !reflect.DeepEqual(got, want) {
+			gotJSON, _ := json.Marshal(got)
+			t.Errorf("merging %s into %s = %s, want %s", test.patch, test.target, gotJSON, test.want)
+		}
+	}
+}
+
+func TestSortItems(t *testing.T) {
+	items := func() []Item {
+		return []Item{
+			mustParseItem(t, `{"id":"1","score":10,"name":"b"}`),
+			mustParseItem(t, `{"id":"2","score":9,"name":"a"}`),
+			mustParseItem(t, `{"id":"3","name":"c"}`),
+			mustParseItem(t, `{"id":"4","score":10}`),
+			mustParseItem(t, `{"id":"5","score":"high"}`),
+		}
+	}
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"score", []string{"2", "1", "4", "5", "3"}},
+		{"-score", []string{"5", "1", "4", "2", "3"}},
+		{"name", []string{"2", "1", "3", "4", "5"}},
+		{"-name", []string{"3", "1", "2", "4", "5"}},
+		{"missing", []string{"1", "2", "3", "4", "5"}},
+	}
+
+	for _, test := range tests {
+		sorted := items()
+		sortItems(sorted, test.sort)
+
+		ids := make([]string, len(sorted))
+		for i, item := range sorted {
+			ids[i] = item[idField].(string)
+		}
+
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("sort=%s gave %v, want %v", test.sort, ids, test.want)
+		}
+	}
+}
+
+func TestListItems(t *testing.T) {
+	service := NewProjectStoreService(newMemoryRepo())
+
+	for _, score := range []int{3, 1, 2} {
+		_, err := service.AddItem("team", "scores", Item{"score": json.Number(strconv.Itoa(score))})
+		if err != nil {
+			t.Fatalf("AddItem returned %v", err)
+		}
+	}
+
+	list, err := service.ListItems("team", "scores", ItemQuery{Sort: "-score", Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("ListItems returned %v", err)
+	}
+
+	if list.Total != 3 || len(list.Items) != 2 {
+		t.Fatalf("got total %d with %d items, want 3 with 2", list.Total, len(list.Items))
+	}
+
+	if list.Items[0]["score"] != json.Number("2") || list.Items[1]["score"] != json.Number("1") {
+		t.Errorf("got scores %v and %v, want 2 and 1", list.Items[0]["score"], list.Items[1]["score"])
+	}
+
+	list, err = service.ListItems("team", "scores", ItemQuery{Limit: 2, Offset: 5})
+	if err != nil || list.Total != 3 || len(list.Items) != 0 {
+		t.Errorf("offset past the end gave %d items, %v", len(list.Items), err)
+	}
+
+	if _, err = service.ListItems("team", "scores", ItemQuery{Sort: "a.b", Limit: 2}); !errors.IsNotValid(err) {
+		t.Errorf("invalid sort returned %v, want NotValid", err)
+	}
+}
+
+func TestUpdateItemKeepsServerFields(t *testing.T) {
+	service := NewProjectStoreService(newMemoryRepo())
+
+	added, err := service.AddItem("team", "posts", Item{"title": "a", "id": "mine"})
+	if err != nil {
+		t.Fatalf("AddItem returned %v", err)
+	}
+
+	id := added[idField].(string)
+	if id == "mine" {
+		t.Fatalf("AddItem kept the client's id")
+	}
+
+	replaced, err := service.ReplaceItem("team", "posts", id, Item{"body": "b", "createdAt": "never"})
+	if err != nil {
+		t.Fatalf("ReplaceItem returned %v", err)
+	}
+
+	if replaced[itemCreatedAt] != added[itemCreatedAt] || replaced[idField] != id || replaced["title"] != nil {
+		t.Errorf("ReplaceItem gave %v", replaced)
+	}
+
+	if _, err = service.PatchItem("team", "posts", "not-a-uuid", Item{}); !errors.IsNotFound(err) {
+		t.Errorf("PatchItem with a bad id returned %v, want NotFound", err)
+	}
+}
+
+func TestDeleteGroupStaysInsideGroup(t *testing.T) {
+	repo := newMemoryRepo()
+	service := NewProjectStoreService(repo)
+
+	service.SetValue("a", "posts", `[]`)
+	repo.values["a:b:posts"] = `[]`
+	repo.hash(collectionKey("a", "posts"))["x"] = `{}`
+	repo.hash(collectionKey("a:b", "posts"))["x"] = `{}`
+
+	keyList, err := service.ListKeys("a", 10, 0)
+	if err != nil || keyList.Total != 1 || keyList.Keys[0].Name != "posts" {
+		t.Fatalf("ListKeys gave %+v, %v", keyList, err)
+	}
+
+	deleted, err := service.DeleteGroup("a")
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteGroup deleted %d, %v, want 2", deleted, err)
+	}
+
+	remaining, _ := repo.ScanKeys("*")
+	sort.Strings(remaining)
+
+	if want := []string{"_collections:a:b:posts", "a:b:posts"}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("DeleteGroup left %v, want %v", remaining, want)
+	}
+}
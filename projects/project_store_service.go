@@ -2,10 +2,27 @@ package projects
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+const (
+	// reservedPrefix marks keys the API keeps for itself, like best seller
+	// snapshots and project metadata, so groups may not start with it.
+	reservedPrefix = "_"
+	metaKeyFormat  = "_projectMeta:%s"
+	createdField   = ":createdAt"
+	updatedField   = ":updatedAt"
+	deleteBatch    = 500
+	// unsafeNameChars are the key separator and the Redis glob characters.
+	unsafeNameChars = ":*?[]\\"
+)
+
 type User struct {
 	MobileEmail string   `json:"mobileEmail"`
 	FullName    string   `json:"fullName"`
@@ -18,6 +35,32 @@ type Username string
 type keyValRepository interface {
 	SetKeyValue(key, value string) error
 	GetValue(key string) (string, error)
+	ScanKeys(pattern string) ([]string, error)
+	DeleteKeys(keys ...string) (int64, error)
+	ValueSizes(keys []string) ([]int64, error)
+	SetHashFields(key string, fields, onlyNew map[string]string) error
+	GetHash(key string) (map[string]string, error)
+	GetHashField(key, field string) (string, error)
+	SetHashFieldNX(key, field, value string) (bool, error)
+	UpdateHashField(key, field string, update func(current string) (string, error)) (string, error)
+	DeleteHashFields(key string, fields ...string) (int64, error)
+}
+
+// KeyInfo describes one key in a group. Keys written before timestamps were
+// tracked have none.
+type KeyInfo struct {
+	Name      string     `json:"name"`
+	Size      int64      `json:"size"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type KeyList struct {
+	Group  string    `json:"group"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+	Keys   []KeyInfo `json:"keys"`
 }
 
 type ProjectStoreService struct {
@@ -42,11 +85,207 @@ func (p *ProjectStoreService) GetValue(groupName, keyName string) string {
 }
 
 func (p *ProjectStoreService) SetValue(groupName, keyName, value string) {
+	// Reserved keys hold data served to everyone, like best seller snapshots.
+	if !IsValidGroupName(groupName) || !IsValidKeyName(keyName) {
+		log.Warnf("Refusing to set invalid key: %s:%s", groupName, keyName)
+		return
+	}
 
 	key := fmt.Sprintf("%s:%s", groupName, keyName)
 
 	err := p.keyValRepo.SetKeyValue(key, value)
 	if err != nil {
 		log.Errorf("Could not set key value: %s:%s\n%+v\n", key, value, err)
+		return
+	}
+
+	now := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+
+	err = p.keyValRepo.SetHashFields(metaKey(groupName),
+		map[string]string{keyName + updatedField: now},
+		map[string]string{keyName + createdField: now})
+	if err != nil {
+		log.Errorf("Could not set key timestamps: %s\n%+v\n", key, err)
+	}
+}
+
+// IsValidGroupName rejects empty names, the prefix reserved for the API's own
+// keys and the characters that would let one group's keys match another's.
+func IsValidGroupName(groupName string) bool {
+	return IsValidKeyName(groupName) && !strings.HasPrefix(groupName, reservedPrefix)
+}
+
+// IsValidKeyName rejects empty names and names with the key separator or
+// Redis glob characters, so a group's keys are exactly those matching
+// "{group}:*".
+func IsValidKeyName(keyName string) bool {
+	return keyName != "" && !strings.ContainsAny(keyName, unsafeNameChars)
+}
+
+// ListKeys returns one page of the group's key names, sorted, with their sizes
+// and timestamps.
+func (p *ProjectStoreService) ListKeys(groupName string, limit, offset int) (KeyList, error) {
+	keys, err := p.groupKeys(groupName)
+	if err != nil {
+		return KeyList{}, errors.Trace(err)
+	}
+
+	keyList := KeyList{
+		Group:  groupName,
+		Total:  len(keys),
+		Limit:  limit,
+		Offset: offset,
+		Keys:   []KeyInfo{},
+	}
+
+	if offset >= len(keys) {
+		return keyList, nil
 	}
+
+	keys = keys[offset:]
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	sizes, err := p.keyValRepo.ValueSizes(keys)
+	if err != nil {
+		return KeyList{}, errors.Trace(err)
+	}
+
+	meta, err := p.keyValRepo.GetHash(metaKey(groupName))
+	if err != nil {
+		return KeyList{}, errors.Trace(err)
+	}
+
+	prefix := groupName + ":"
+	for i, key := range keys {
+		keyName := strings.TrimPrefix(key, prefix)
+
+		keyList.Keys = append(keyList.Keys, KeyInfo{
+			Name:      keyName,
+			Size:      sizes[i],
+			CreatedAt: parseMillis(meta[keyName+createdField]),
+			UpdatedAt: parseMillis(meta[keyName+updatedField]),
+		})
+	}
+
+	return keyList, nil
+}
+
+// DeleteKey deletes one key, returning NotFound if it did not exist.
+func (p *ProjectStoreService) DeleteKey(groupName, keyName string) error {
+	if !IsValidGroupName(groupName) || !IsValidKeyName(keyName) {
+		return errors.NotValidf("key %s:%s", groupName, keyName)
+	}
+
+	key := fmt.Sprintf("%s:%s", groupName, keyName)
+
+	deleted, err := p.keyValRepo.DeleteKeys(key)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	_, err = p.keyValRepo.DeleteHashFields(metaKey(groupName), keyName+createdField, keyName+updatedField)
+	if err != nil {
+		log.Errorf("Could not delete key timestamps: %s\n%+v\n", key, err)
+	}
+
+	if deleted == 0 {
+		return errors.NotFoundf("key %s", key)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes every key and collection in the group, returning how
+// many there were.
+func (p *ProjectStoreService) DeleteGroup(groupName string) (int64, error) {
+	if !IsValidGroupName(groupName) {
+		return 0, errors.NotValidf("group %s", groupName)
+	}
+
+	keys, err := p.groupKeys(groupName)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	collections, err := p.groupCollections(groupName)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	keys = append(keys, collections...)
+
+	var deleted int64
+
+	for start := 0; start < len(keys); start += deleteBatch {
+		end := start + deleteBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		count, err := p.keyValRepo.DeleteKeys(keys[start:end]...)
+		if err != nil {
+			return deleted, errors.Trace(err)
+		}
+
+		deleted += count
+	}
+
+	_, err = p.keyValRepo.DeleteKeys(metaKey(groupName))
+
+	return deleted, errors.Trace(err)
+}
+
+// groupKeys returns the group's keys, sorted. Keys written before names were
+// validated may hold a further separator, and belong to another group.
+func (p *ProjectStoreService) groupKeys(groupName string) ([]string, error) {
+	prefix := groupName + ":"
+
+	scanned, err := p.keyValRepo.ScanKeys(escapeGlob(prefix) + "*")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	keys := make([]string, 0, len(scanned))
+	for _, key := range scanned {
+		if !strings.Contains(strings.TrimPrefix(key, prefix), ":") {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func metaKey(groupName string) string {
+	return fmt.Sprintf(metaKeyFormat, groupName)
+}
+
+// escapeGlob escapes the characters Redis MATCH patterns treat specially.
+func escapeGlob(value string) string {
+	var escaped strings.Builder
+
+	for _, char := range value {
+		switch char {
+		case '*', '?', '[', ']', '\\':
+			escaped.WriteRune('\\')
+		}
+
+		escaped.WriteRune(char)
+	}
+
+	return escaped.String()
+}
+
+func parseMillis(value string) *time.Time {
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	parsed := time.Unix(0, millis*int64(time.Millisecond)).UTC()
+
+	return &parsed
 }
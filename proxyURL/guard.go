@@ -0,0 +1,171 @@
+package proxyURL
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/upstream"
+)
+
+const maxRedirects = 5
+
+// blockedNetworks are reachable from the API host but must never be reached on
+// behalf of a student: shared address space, benchmarking, reserved ranges,
+// NAT64 and 6to4 prefixes that embed an IPv4 address, and the cloud metadata
+// endpoints. Loopback, link-local and private ranges are checked with the
+// net.IP helpers.
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"169.254.169.254/32",
+	"64:ff9b::/96",
+	"2002::/16",
+	"fd00:ec2::254/128",
+)
+
+// BlockedURLError is returned when a proxied URL, a redirect or the address
+// it resolves to is not allowed.
+type BlockedURLError struct {
+	URL    string
+	Reason string
+}
+
+func (e *BlockedURLError) Error() string {
+	return fmt.Sprintf("proxying %s is not allowed: %s", e.URL, e.Reason)
+}
+
+// Permanent tells retrying clients not to try again.
+func (e *BlockedURLError) Permanent() bool {
+	return true
+}
+
+// URLGuard decides which URLs the proxy may fetch. An empty domain allowlist
+// allows any public host.
+type URLGuard struct {
+	allowedDomains []string
+}
+
+func NewURLGuard(allowedDomains []string) *URLGuard {
+	guard := &URLGuard{}
+
+	for _, domain := range allowedDomains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			guard.allowedDomains = append(guard.allowedDomains, domain)
+		}
+	}
+
+	return guard
+}
+
+// CheckURL rejects anything but http and https URLs to allowlisted hosts.
+// Addresses are checked again at dial time, after DNS resolution.
+func (g *URLGuard) CheckURL(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return &BlockedURLError{URL: target.String(), Reason: "only http and https are supported"}
+	}
+
+	host := strings.Trim(strings.ToLower(target.Hostname()), ".")
+	if host == "" {
+		return &BlockedURLError{URL: target.String(), Reason: "missing host"}
+	}
+
+	if target.User != nil {
+		return &BlockedURLError{URL: target.String(), Reason: "credentials in URLs are not supported"}
+	}
+
+	if ip := net.ParseIP(host); ip != nil && IsBlockedIP(ip) {
+		return &BlockedURLError{URL: target.String(), Reason: "internal address"}
+	}
+
+	if len(g.allowedDomains) == 0 {
+		return nil
+	}
+
+	for _, domain := range g.allowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+
+	return &BlockedURLError{URL: target.String(), Reason: "host is not in the allowlist"}
+}
+
+// Configure returns config with connections to internal addresses refused,
+// even when a public name resolves to one, and redirects checked like the
+// original URL. An outbound proxy would hide the resolved address, so it is
+// dropped.
+func (g *URLGuard) Configure(config upstream.Config) upstream.Config {
+	if config.ProxyURL != "" {
+		log.Warnf("Ignoring outbound proxy for %s so resolved addresses can be checked", config.Name)
+		config.ProxyURL = ""
+	}
+
+	config.DialControl = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || IsBlockedIP(ip) {
+			return &BlockedURLError{URL: address, Reason: "resolves to an internal address"}
+		}
+
+		return nil
+	}
+
+	config.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return &BlockedURLError{URL: req.URL.String(), Reason: "too many redirects"}
+		}
+
+		return g.CheckURL(req.URL)
+	}
+
+	return config
+}
+
+// IsBlockedIP reports whether ip is loopback, link-local, private, multicast,
+// unspecified or in another range that must not be proxied.
+func IsBlockedIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return true
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
@@ -1,51 +1,252 @@
 package proxyURL
 
 import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 
+	"github.com/juju/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/y3sh/go143/upstream"
 )
 
-type HTTPClient interface {
-	Get(url string) (resp *http.Response, err error)
-	Do(req *http.Request) (resp *http.Response, err error)
+const (
+	// DefaultMaxResponseBytes caps proxied bodies so a single request can't
+	// stream an unbounded download through the API.
+	DefaultMaxResponseBytes = 10 << 20
+	DefaultMaxRequestBytes  = 1 << 20
+)
+
+// DefaultMethods are the methods the proxy forwards unless configured
+// otherwise.
+var DefaultMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// forwardedRequestHeaders are copied from the student's request upstream.
+var forwardedRequestHeaders = []string{
+	"Accept",
+	"Accept-Language",
+	"Authorization",
+	"Content-Type",
+	"Range",
+	"If-Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+}
+
+// forwardedResponseHeaders are safe to pass back to the browser.
+var forwardedResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"Cache-Control",
+	"ETag",
+	"Last-Modified",
+	"Expires",
+	"Age",
+	"X-Cache",
 }
 
 type ProxyClient struct {
-	httpClient HTTPClient
+	httpClient       upstream.HTTPClient
+	guard            *URLGuard
+	methods          map[string]bool
+	maxRequestBytes  int64
+	maxResponseBytes int64
+}
+
+// Response is an upstream response with only the safe headers kept. Body must
+// be closed and fails with a *TooLargeError once the size limit is passed.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       io.ReadCloser
+}
+
+// TooLargeError is returned when an upstream body is larger than the proxy
+// allows.
+type TooLargeError struct {
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("upstream response is larger than %d bytes", e.Limit)
+}
+
+// RequestTooLargeError is returned when a body sent through the proxy is
+// larger than the proxy allows.
+type RequestTooLargeError struct {
+	Limit int64
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", e.Limit)
 }
 
-func NewProxyClient(httpClient HTTPClient) *ProxyClient {
-	return &ProxyClient{
-		httpClient: httpClient,
+// NewProxyClient proxies the URLs guard allows through httpClient, which
+// should be built from a config passed through guard.Configure so resolved
+// addresses and redirects are checked too.
+func NewProxyClient(httpClient upstream.HTTPClient, guard *URLGuard) *ProxyClient {
+	proxyClient := &ProxyClient{
+		httpClient:       httpClient,
+		guard:            guard,
+		maxRequestBytes:  DefaultMaxRequestBytes,
+		maxResponseBytes: DefaultMaxResponseBytes,
+	}
+	proxyClient.SetMethods(DefaultMethods)
+
+	return proxyClient
+}
+
+// SetMethods sets which HTTP methods the proxy forwards.
+func (r *ProxyClient) SetMethods(methods []string) {
+	r.methods = map[string]bool{}
+
+	for _, method := range methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method != "" {
+			r.methods[method] = true
+		}
 	}
 }
 
-func (r *ProxyClient) GetProxyURL(url string) []byte {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// Methods returns the enabled methods, sorted.
+func (r *ProxyClient) Methods() []string {
+	methods := make([]string, 0, len(r.methods))
+	for method := range r.methods {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// SetMaxRequestBytes sets the largest body the proxy will send upstream.
+func (r *ProxyClient) SetMaxRequestBytes(maxRequestBytes int64) {
+	r.maxRequestBytes = maxRequestBytes
+}
+
+// SetMaxResponseBytes sets the largest body the proxy will stream.
+func (r *ProxyClient) SetMaxResponseBytes(maxResponseBytes int64) {
+	r.maxResponseBytes = maxResponseBytes
+}
+
+// Proxy sends a method request to rawURL with body, forwarding the
+// allowlisted headers from header. It returns a MethodNotAllowed error for
+// disabled methods, a Forbidden error when the URL, a redirect or a resolved
+// address is blocked, NotValid when it can't be parsed, a
+// *RequestTooLargeError when body is over the limit and a *TooLargeError when
+// the upstream declares a body over the limit.
+func (r *ProxyClient) Proxy(method, rawURL string, header http.Header, body io.Reader) (*Response, error) {
+	if !r.methods[method] {
+		return nil, errors.MethodNotAllowedf("proxying %s requests is not enabled", method)
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.NotValidf("proxy URL %q", rawURL)
+	}
+
+	err = r.guard.CheckURL(target)
 	if err != nil {
-		log.Errorf("Could not make req for googleBookRes, %s", err.Error())
-		return nil
+		return nil, errors.Forbiddenf("%s", err.Error())
+	}
+
+	var reqBody io.Reader
+	if body != nil && method != http.MethodGet {
+		bodyBytes, err := ioutil.ReadAll(io.LimitReader(body, r.maxRequestBytes+1))
+		if err != nil {
+			return nil, errors.Annotate(err, "could not read proxy request body")
+		}
+
+		if int64(len(bodyBytes)) > r.maxRequestBytes {
+			return nil, &RequestTooLargeError{Limit: r.maxRequestBytes}
+		}
+
+		reqBody = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequest(method, target.String(), reqBody)
+	if err != nil {
+		return nil, errors.NotValidf("proxy URL %q", rawURL)
+	}
+
+	for _, name := range forwardedRequestHeaders {
+		if value := header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
 	}
 
 	res, err := r.httpClient.Do(req)
 	if err != nil {
-		log.Errorf("Could not fetch %s, %s", url, err.Error())
-		return nil
+		var blocked *BlockedURLError
+		if stderrors.As(err, &blocked) {
+			log.Warnf("Blocked proxy request for %s, %s", rawURL, blocked.Error())
+			return nil, errors.Forbiddenf("%s", blocked.Error())
+		}
+
+		return nil, errors.Annotatef(err, "could not fetch %s", rawURL)
 	}
 
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		log.Errorf("Non 200 status: %d, %s", res.StatusCode, url)
-		return nil
+	if res.ContentLength > r.maxResponseBytes {
+		res.Body.Close()
+		return nil, &TooLargeError{Limit: r.maxResponseBytes}
 	}
 
-	body, readErr := ioutil.ReadAll(res.Body)
-	if readErr != nil {
-		log.Errorf("Could not read polygon res: %s, %s", url, err.Error())
-		return nil
+	proxyRes := &Response{
+		StatusCode: res.StatusCode,
+		Header:     http.Header{},
+		Body: &limitedBody{
+			body:      res.Body,
+			remaining: r.maxResponseBytes,
+			limit:     r.maxResponseBytes,
+		},
 	}
 
-	return body
+	for _, name := range forwardedResponseHeaders {
+		if values := res.Header.Values(name); len(values) > 0 {
+			proxyRes.Header[name] = values
+		}
+	}
+
+	return proxyRes, nil
+}
+
+// limitedBody reads up to limit bytes and then fails instead of silently
+// truncating, so callers can abort the response.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &TooLargeError{Limit: b.limit}
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+
+	if b.remaining < 0 {
+		return n + int(b.remaining), &TooLargeError{Limit: b.limit}
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
 }
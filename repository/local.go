@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+)
+
+// LocalRepository stores objects as files under a directory, for running
+// without S3 credentials.
+type LocalRepository struct {
+	dir string
+}
+
+func NewLocalRepository(dir string) (*LocalRepository, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, errors.Annotatef(err, "unable to create storage dir %s", dir)
+	}
+
+	return &LocalRepository{
+		dir: dir,
+	}, nil
+}
+
+func (l *LocalRepository) PutObject(key, _ string, data []byte) error {
+	path := l.objectPath(key)
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	// Write then rename so readers never see a partial object.
+	tmpPath := path + ".tmp"
+
+	err = ioutil.WriteFile(tmpPath, data, 0o644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	return errors.Trace(os.Rename(tmpPath, path))
+}
+
+// GetObject returns the object bytes and a content type sniffed from them, or
+// a NotFound error when the key does not exist.
+func (l *LocalRepository) GetObject(key string) ([]byte, string, error) {
+	data, err := ioutil.ReadFile(l.objectPath(key))
+	if os.IsNotExist(err) {
+		return nil, "", errors.NotFoundf("local object %s", key)
+	}
+
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+
+	return data, http.DetectContentType(data), nil
+}
+
+// objectPath roots the key before cleaning it so ../ cannot leave the dir.
+func (l *LocalRepository) objectPath(key string) string {
+	return filepath.Join(l.dir, filepath.Clean("/"+key))
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/juju/errors"
@@ -15,6 +16,10 @@ type RedisRepository struct {
 const (
 	redisAddr = "0.0.0.0"
 	redisPort = 6379
+	scanCount = 500
+	// txRetries bounds how often an optimistic WATCH transaction is retried
+	// when another client changes the watched key first.
+	txRetries = 10
 )
 
 var ctx = context.Background()
@@ -46,6 +51,15 @@ func (r *RedisRepository) SetKeyValue(key, value string) error {
 	return nil
 }
 
+func (r *RedisRepository) SetKeyValueTTL(key, value string, ttl time.Duration) error {
+	err := r.rdb.Set(ctx, key, value, ttl).Err()
+	if err != nil {
+		return errors.Wrap(err, errors.Errorf("unable to set key with ttl: %s", key))
+	}
+
+	return nil
+}
+
 func (r *RedisRepository) GetValue(key string) (string, error) {
 	val, err := r.rdb.Get(ctx, key).Result()
 	if err != nil {
@@ -54,3 +68,192 @@ func (r *RedisRepository) GetValue(key string) (string, error) {
 
 	return val, nil
 }
+
+// GetValues returns the string value of each key, in the order of keys, using
+// one round trip. Missing keys and keys holding other types give "".
+func (r *RedisRepository) GetValues(keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+
+	results, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Errorf("unable to get %d keys", len(keys)))
+	}
+
+	values := make([]string, len(keys))
+	for i, result := range results {
+		if value, ok := result.(string); ok {
+			values[i] = value
+		}
+	}
+
+	return values, nil
+}
+
+// ScanKeys returns every key matching the glob pattern, iterating with SCAN so
+// Redis isn't blocked the way KEYS would.
+func (r *RedisRepository) ScanKeys(pattern string) ([]string, error) {
+	var keys []string
+
+	iter := r.rdb.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.Errorf("unable to scan keys: %s", pattern))
+	}
+
+	return keys, nil
+}
+
+// DeleteKeys deletes keys, returning how many existed.
+func (r *RedisRepository) DeleteKeys(keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := r.rdb.Del(ctx, keys...).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, errors.Errorf("unable to delete %d keys", len(keys)))
+	}
+
+	return deleted, nil
+}
+
+// ValueSizes returns the length in bytes of each string value, in the order of
+// keys, using one round trip.
+func (r *RedisRepository) ValueSizes(keys []string) ([]int64, error) {
+	pipe := r.rdb.Pipeline()
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.StrLen(ctx, key)
+	}
+
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, errors.Errorf("unable to get sizes of %d keys", len(keys)))
+	}
+
+	sizes := make([]int64, len(keys))
+	for i, cmd := range cmds {
+		sizes[i] = cmd.Val()
+	}
+
+	return sizes, nil
+}
+
+// SetHashFields sets fields on the hash at key, and onlyNew fields only when
+// they are not set yet, in one transaction.
+func (r *RedisRepository) SetHashFields(key string, fields, onlyNew map[string]string) error {
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for field, value := range onlyNew {
+			pipe.HSetNX(ctx, key, field, value)
+		}
+
+		if len(fields) > 0 {
+			pipe.HSet(ctx, key, fields)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, errors.Errorf("unable to set hash fields: %s", key))
+	}
+
+	return nil
+}
+
+func (r *RedisRepository) GetHash(key string) (map[string]string, error) {
+	fields, err := r.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.Errorf("unable to get hash: %s", key))
+	}
+
+	return fields, nil
+}
+
+// GetHashField returns one field of the hash at key, or a NotFound error when
+// it is not set.
+func (r *RedisRepository) GetHashField(key, field string) (string, error) {
+	val, err := r.rdb.HGet(ctx, key, field).Result()
+	if err == redis.Nil {
+		return "", errors.NotFoundf("hash field %s in %s", field, key)
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, errors.Errorf("unable to get hash field: %s %s", key, field))
+	}
+
+	return val, nil
+}
+
+// SetHashFieldNX sets field on the hash at key unless it is already set,
+// reporting whether it was set.
+func (r *RedisRepository) SetHashFieldNX(key, field, value string) (bool, error) {
+	set, err := r.rdb.HSetNX(ctx, key, field, value).Result()
+	if err != nil {
+		return false, errors.Wrap(err, errors.Errorf("unable to set hash field: %s %s", key, field))
+	}
+
+	return set, nil
+}
+
+// UpdateHashField replaces an existing field of the hash at key with the value
+// update returns for its current one. The hash is watched so a concurrent
+// write makes the transaction retry instead of being lost. A NotFound error is
+// returned when the field is not set.
+func (r *RedisRepository) UpdateHashField(key, field string, update func(current string) (string, error)) (string, error) {
+	var updated string
+
+	txf := func(tx *redis.Tx) error {
+		current, err := tx.HGet(ctx, key, field).Result()
+		if err == redis.Nil {
+			return errors.NotFoundf("hash field %s in %s", field, key)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, errors.Errorf("unable to get hash field: %s %s", key, field))
+		}
+
+		updated, err = update(current)
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, field, updated)
+			return nil
+		})
+
+		return err
+	}
+
+	for i := 0; i < txRetries; i++ {
+		err := r.rdb.Watch(ctx, txf, key)
+		if err == redis.TxFailedErr {
+			continue
+		}
+
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+
+		return updated, nil
+	}
+
+	return "", errors.Errorf("unable to update hash field %s %s: too many concurrent writes", key, field)
+}
+
+// DeleteHashFields deletes fields from the hash at key, returning how many
+// were set.
+func (r *RedisRepository) DeleteHashFields(key string, fields ...string) (int64, error) {
+	deleted, err := r.rdb.HDel(ctx, key, fields...).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, errors.Errorf("unable to delete hash fields: %s", key))
+	}
+
+	return deleted, nil
+}
@@ -3,8 +3,10 @@ package repository
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -55,3 +57,40 @@ func (s *S3Repository) AddFileToS3(name string, reader *bytes.Reader) (string, e
 
 	return url, errors.Trace(err)
 }
+
+func (s *S3Repository) PutObject(key, contentType string, data []byte) error {
+	_, err := s.s3Session.PutObject(&s3.PutObjectInput{
+		Bucket:       aws.String(S3_BUCKET),
+		Key:          aws.String(key),
+		ACL:          aws.String(S3_ACL),
+		CacheControl: aws.String("public, max-age=31536000"),
+		ContentType:  aws.String(contentType),
+		Body:         bytes.NewReader(data),
+	})
+
+	return errors.Trace(err)
+}
+
+// GetObject returns the object bytes and content type, or a NotFound error
+// when the key does not exist.
+func (s *S3Repository) GetObject(key string) ([]byte, string, error) {
+	out, err := s.s3Session.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(S3_BUCKET),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, "", errors.NotFoundf("s3 object %s", key)
+		}
+
+		return nil, "", errors.Trace(err)
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", errors.Trace(err)
+	}
+
+	return data, aws.StringValue(out.ContentType), nil
+}
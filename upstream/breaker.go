@@ -0,0 +1,263 @@
+package upstream
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitOpenError is returned without calling upstream while a host's
+// breaker is open.
+type CircuitOpenError struct {
+	Host       string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s, retry in %s", e.Host, e.RetryAfter.Round(time.Second))
+}
+
+// Permanent tells retrying clients not to try again.
+func (e *CircuitOpenError) Permanent() bool {
+	return true
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for a Retry-After
+// header.
+func (e *CircuitOpenError) RetryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// IsCircuitOpen reports whether err was caused by an open breaker.
+func IsCircuitOpen(err error) bool {
+	var openErr *CircuitOpenError
+
+	return stderrors.As(errors.Cause(err), &openErr)
+}
+
+// AsCircuitOpen returns the *CircuitOpenError behind err, if any.
+func AsCircuitOpen(err error) (*CircuitOpenError, bool) {
+	var openErr *CircuitOpenError
+	if stderrors.As(errors.Cause(err), &openErr) {
+		return openErr, true
+	}
+
+	return nil, false
+}
+
+// BreakerStatus is a breaker's state for the status endpoint.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Trips               uint64     `json:"trips"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+}
+
+// Breakers holds one circuit breaker per upstream host. A breaker opens after
+// threshold consecutive failures, rejects requests for cooldown, then lets a
+// single probe through: success closes it, failure opens it again.
+type Breakers struct {
+	mutex    *sync.Mutex
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	trips     uint64
+	openedAt  time.Time
+	probing   bool
+}
+
+func NewBreakers() *Breakers {
+	return &Breakers{
+		mutex:    &sync.Mutex{},
+		breakers: make(map[string]*breaker),
+		now:      time.Now,
+	}
+}
+
+// Statuses reports every breaker keyed by host.
+func (b *Breakers) Statuses() map[string]BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	statuses := make(map[string]BreakerStatus, len(b.breakers))
+	for host, hostBreaker := range b.breakers {
+		status := BreakerStatus{
+			State:               hostBreaker.currentState(b.now()),
+			ConsecutiveFailures: hostBreaker.failures,
+			Trips:               hostBreaker.trips,
+		}
+
+		if hostBreaker.state != BreakerClosed {
+			openedAt := hostBreaker.openedAt
+			status.OpenedAt = &openedAt
+		}
+
+		statuses[host] = status
+	}
+
+	return statuses
+}
+
+// allow reserves a request to host, returning a *CircuitOpenError when the
+// breaker is open or already probing. probe is set when the request is the
+// single one let through a half-open breaker.
+func (b *Breakers) allow(host string, threshold int, cooldown time.Duration) (probe bool, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	hostBreaker, ok := b.breakers[host]
+	if !ok {
+		hostBreaker = &breaker{
+			threshold: threshold,
+			cooldown:  cooldown,
+			state:     BreakerClosed,
+		}
+		b.breakers[host] = hostBreaker
+	}
+
+	now := b.now()
+
+	switch hostBreaker.currentState(now) {
+	case BreakerClosed:
+		return false, nil
+	case BreakerHalfOpen:
+		if !hostBreaker.probing {
+			hostBreaker.state = BreakerHalfOpen
+			hostBreaker.probing = true
+
+			return true, nil
+		}
+
+		return false, &CircuitOpenError{Host: host, RetryAfter: hostBreaker.cooldown}
+	}
+
+	return false, &CircuitOpenError{Host: host, RetryAfter: hostBreaker.openedAt.Add(hostBreaker.cooldown).Sub(now)}
+}
+
+// record counts the outcome of a request admitted by allow. Only the probe
+// can close or reopen a breaker that is not closed, so a late answer to a
+// request sent before it opened is ignored.
+func (b *Breakers) record(host string, probe, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	hostBreaker := b.breakers[host]
+
+	if probe {
+		hostBreaker.probing = false
+	} else if hostBreaker.state != BreakerClosed {
+		return
+	}
+
+	if !failed {
+		if hostBreaker.state != BreakerClosed {
+			log.Infof("Circuit closed for %s", host)
+		}
+
+		hostBreaker.state = BreakerClosed
+		hostBreaker.failures = 0
+
+		return
+	}
+
+	hostBreaker.failures++
+
+	if probe || hostBreaker.failures >= hostBreaker.threshold {
+		if hostBreaker.state == BreakerClosed {
+			hostBreaker.trips++
+		}
+
+		log.Warnf("Circuit open for %s after %d consecutive failures", host, hostBreaker.failures)
+		hostBreaker.state = BreakerOpen
+		hostBreaker.openedAt = b.now()
+	}
+}
+
+// release gives back a request admitted by allow without counting it, so a
+// probe that never reached the host lets the next request probe instead.
+func (b *Breakers) release(host string, probe bool) {
+	if !probe {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.breakers[host].probing = false
+}
+
+func (h *breaker) currentState(now time.Time) string {
+	if h.state == BreakerOpen && !now.Before(h.openedAt.Add(h.cooldown)) {
+		return BreakerHalfOpen
+	}
+
+	return h.state
+}
+
+// BreakerClient fails fast with a *CircuitOpenError while the breaker for a
+// request's host is open. Network errors and 5xx responses count as failures.
+type BreakerClient struct {
+	next      HTTPClient
+	breakers  *Breakers
+	threshold int
+	cooldown  time.Duration
+}
+
+func NewBreakerClient(next HTTPClient, breakers *Breakers, config Config) *BreakerClient {
+	return &BreakerClient{
+		next:      next,
+		breakers:  breakers,
+		threshold: config.BreakerThreshold,
+		cooldown:  config.BreakerCooldown,
+	}
+}
+
+func (c *BreakerClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *BreakerClient) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	probe, err := c.breakers.allow(host, c.threshold, c.cooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.next.Do(req)
+
+	// A caller giving up says nothing about the upstream's health.
+	if err != nil && req.Context().Err() != nil {
+		c.breakers.release(host, probe)
+		return nil, err
+	}
+
+	c.breakers.record(host, probe, err != nil || res.StatusCode >= http.StatusInternalServerError)
+
+	return res, err
+}
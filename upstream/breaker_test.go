@@ -0,0 +1,263 @@
+package upstream
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubClient answers every request with status, after waiting on release
+// when it is set.
+type stubClient struct {
+	status  int32
+	calls   int32
+	release chan struct{}
+}
+
+func (s *stubClient) Get(url string) (*http.Response, error) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	return s.Do(req)
+}
+
+func (s *stubClient) Do(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&s.calls, 1)
+
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	return &http.Response{
+		StatusCode: int(atomic.LoadInt32(&s.status)),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil
+}
+
+type breakerTest struct {
+	t        *testing.T
+	now      time.Time
+	breakers *Breakers
+	stub     *stubClient
+	client   *BreakerClient
+}
+
+func newBreakerTest(t *testing.T) *breakerTest {
+	test := &breakerTest{
+		t:        t,
+		now:      time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		breakers: NewBreakers(),
+		stub:     &stubClient{status: http.StatusOK},
+	}
+
+	test.breakers.now = func() time.Time { return test.now }
+	test.client = NewBreakerClient(test.stub, test.breakers, Config{BreakerThreshold: 3, BreakerCooldown: 10 * time.Second})
+
+	return test
+}
+
+func (b *breakerTest) get() error {
+	res, err := b.client.Get("https://api.example.com/")
+	if err == nil {
+		res.Body.Close()
+	}
+
+	return err
+}
+
+func (b *breakerTest) expectState(state string) {
+	b.t.Helper()
+
+	if got := b.breakers.Statuses()["api.example.com"].State; got != state {
+		b.t.Fatalf("breaker is %s, want %s", got, state)
+	}
+}
+
+func (b *breakerTest) open() {
+	b.t.Helper()
+
+	atomic.StoreInt32(&b.stub.status, http.StatusServiceUnavailable)
+
+	for i := 0; i < 3; i++ {
+		if err := b.get(); err != nil {
+			b.t.Fatalf("request %d returned %v before the breaker opened", i, err)
+		}
+	}
+
+	b.expectState(BreakerOpen)
+}
+
+func TestBreakerOpensAtThreshold(t *testing.T) {
+	test := newBreakerTest(t)
+	atomic.StoreInt32(&test.stub.status, http.StatusBadGateway)
+
+	_ = test.get()
+	_ = test.get()
+	test.expectState(BreakerClosed)
+
+	_ = test.get()
+	test.expectState(BreakerOpen)
+
+	err := test.get()
+	openErr, ok := AsCircuitOpen(err)
+	if !ok || openErr.RetryAfterSeconds() != 10 {
+		t.Fatalf("open breaker returned %v, want a circuit open error retrying in 10s", err)
+	}
+
+	if calls := atomic.LoadInt32(&test.stub.calls); calls != 3 {
+		t.Errorf("upstream saw %d requests, want 3", calls)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	test := newBreakerTest(t)
+	atomic.StoreInt32(&test.stub.status, http.StatusInternalServerError)
+
+	_ = test.get()
+	_ = test.get()
+
+	atomic.StoreInt32(&test.stub.status, http.StatusOK)
+	_ = test.get()
+
+	atomic.StoreInt32(&test.stub.status, http.StatusInternalServerError)
+	_ = test.get()
+	_ = test.get()
+
+	test.expectState(BreakerClosed)
+}
+
+func TestBreakerProbeSuccessCloses(t *testing.T) {
+	test := newBreakerTest(t)
+	test.open()
+
+	test.now = test.now.Add(10 * time.Second)
+	test.expectState(BreakerHalfOpen)
+
+	atomic.StoreInt32(&test.stub.status, http.StatusOK)
+	if err := test.get(); err != nil {
+		t.Fatalf("probe returned %v", err)
+	}
+
+	test.expectState(BreakerClosed)
+
+	if failures := test.breakers.Statuses()["api.example.com"].ConsecutiveFailures; failures != 0 {
+		t.Errorf("closed breaker kept %d failures", failures)
+	}
+}
+
+func TestBreakerProbeFailureReopens(t *testing.T) {
+	test := newBreakerTest(t)
+	test.open()
+
+	test.now = test.now.Add(10 * time.Second)
+	if err := test.get(); err != nil {
+		t.Fatalf("probe returned %v", err)
+	}
+
+	test.expectState(BreakerOpen)
+
+	if err := test.get(); !IsCircuitOpen(err) {
+		t.Fatalf("reopened breaker returned %v", err)
+	}
+
+	if trips := test.breakers.Statuses()["api.example.com"].Trips; trips != 1 {
+		t.Errorf("breaker tripped %d times, want 1", trips)
+	}
+}
+
+func TestBreakerAdmitsOneConcurrentProbe(t *testing.T) {
+	test := newBreakerTest(t)
+	test.open()
+
+	test.now = test.now.Add(10 * time.Second)
+	atomic.StoreInt32(&test.stub.status, http.StatusOK)
+	atomic.StoreInt32(&test.stub.calls, 0)
+	test.stub.release = make(chan struct{})
+
+	const callers = 10
+
+	var wg sync.WaitGroup
+
+	var rejected int32
+
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if IsCircuitOpen(test.get()) {
+				atomic.AddInt32(&rejected, 1)
+			}
+		}()
+	}
+
+	for atomic.LoadInt32(&test.stub.calls)+atomic.LoadInt32(&rejected) < callers {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(test.stub.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&test.stub.calls); calls != 1 {
+		t.Errorf("%d probes reached upstream, want 1", calls)
+	}
+
+	if rejected != callers-1 {
+		t.Errorf("%d callers were rejected, want %d", rejected, callers-1)
+	}
+
+	test.expectState(BreakerClosed)
+}
+
+func TestBreakerCancelledProbeDoesNotClose(t *testing.T) {
+	test := newBreakerTest(t)
+	test.open()
+
+	test.now = test.now.Add(10 * time.Second)
+	test.stub.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.example.com/", nil)
+	if _, err := test.client.Do(req); err == nil {
+		t.Fatalf("cancelled probe succeeded")
+	}
+
+	test.expectState(BreakerHalfOpen)
+
+	if failures := test.breakers.Statuses()["api.example.com"].ConsecutiveFailures; failures != 3 {
+		t.Errorf("cancelled probe changed failures to %d", failures)
+	}
+
+	test.stub.release = nil
+	atomic.StoreInt32(&test.stub.status, http.StatusOK)
+
+	if err := test.get(); err != nil {
+		t.Fatalf("next probe returned %v", err)
+	}
+
+	test.expectState(BreakerClosed)
+}
+
+func TestBreakerIgnoresLateSuccess(t *testing.T) {
+	test := newBreakerTest(t)
+
+	probe, err := test.breakers.allow("api.example.com", 3, 10*time.Second)
+	if err != nil || probe {
+		t.Fatalf("closed breaker allowed probe %v, %v", probe, err)
+	}
+
+	test.open()
+	test.breakers.record("api.example.com", probe, false)
+	test.expectState(BreakerOpen)
+}
@@ -0,0 +1,150 @@
+package upstream
+
+import (
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// idempotentMethods may be sent again without changing the result.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+var retryStatuses = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// permanent is implemented by errors, such as a blocked address, that another
+// attempt can't fix.
+type permanent interface {
+	Permanent() bool
+}
+
+// RetryClient retries idempotent requests that fail with a network error or a
+// 502, 503 or 504, sleeping a random "full jitter" backoff between attempts.
+type RetryClient struct {
+	next       HTTPClient
+	name       string
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+	randMutex  *sync.Mutex
+	rand       *rand.Rand
+}
+
+func NewRetryClient(next HTTPClient, config Config) *RetryClient {
+	return &RetryClient{
+		next:       next,
+		name:       config.Name,
+		maxRetries: config.MaxRetries,
+		baseDelay:  config.RetryBaseDelay,
+		maxDelay:   config.RetryMaxDelay,
+		randMutex:  &sync.Mutex{},
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (c *RetryClient) Get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Do(req)
+}
+
+func (c *RetryClient) Do(req *http.Request) (*http.Response, error) {
+	canRetry := idempotentMethods[req.Method] && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.next.Do(req)
+
+		if !canRetry || attempt >= c.maxRetries || !shouldRetry(req.Context(), res, err) {
+			return res, err
+		}
+
+		if res != nil {
+			_, _ = io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		delay := c.backoff(attempt)
+		log.Warnf("Retrying %s %s for %s in %s, attempt %d of %d", req.Method, req.URL.Host, c.name, delay, attempt+1, c.maxRetries)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// backoff picks a random delay up to baseDelay doubled per attempt, capped at
+// maxDelay.
+func (c *RetryClient) backoff(attempt int) time.Duration {
+	ceiling := c.baseDelay << uint(attempt)
+	if ceiling > c.maxDelay || ceiling <= 0 {
+		ceiling = c.maxDelay
+	}
+
+	if ceiling <= 0 {
+		return 0
+	}
+
+	c.randMutex.Lock()
+	defer c.randMutex.Unlock()
+
+	return time.Duration(c.rand.Int63n(int64(ceiling)) + 1)
+}
+
+func shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return !isPermanent(err)
+	}
+
+	return retryStatuses[res.StatusCode]
+}
+
+// isPermanent reports whether err is a certificate failure or marked
+// permanent, so retrying would only fail again.
+func isPermanent(err error) bool {
+	var permanentErr permanent
+	if stderrors.As(err, &permanentErr) && permanentErr.Permanent() {
+		return true
+	}
+
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+
+	return stderrors.As(err, &unknownAuthority) || stderrors.As(err, &hostname) || stderrors.As(err, &invalid)
+}
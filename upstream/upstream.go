@@ -0,0 +1,168 @@
+// Package upstream builds the HTTP clients used to reach third-party APIs,
+// each configured separately with its own timeouts, retries, TLS trust and
+// outbound proxy.
+package upstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/juju/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultDialTimeout      = 5 * time.Second
+	DefaultHandshakeTimeout = 5 * time.Second
+	DefaultMaxRetries       = 2
+	DefaultRetryBaseDelay   = 200 * time.Millisecond
+	DefaultRetryMaxDelay    = 2 * time.Second
+)
+
+// HTTPClient is satisfied by *http.Client and by every wrapper around one.
+type HTTPClient interface {
+	Get(url string) (resp *http.Response, err error)
+	Do(req *http.Request) (resp *http.Response, err error)
+}
+
+// Config describes the client for one integration.
+type Config struct {
+	// Name identifies the integration in logs.
+	Name string
+	// Timeout bounds a single attempt, including reading the body.
+	Timeout          time.Duration
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	// MaxRetries is how many times an idempotent request is retried after a
+	// network error or a 502, 503 or 504.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// BreakerThreshold is how many consecutive failures open a host's
+	// breaker; zero disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Breakers is shared by every client so their state can be reported
+	// together. A nil Breakers disables the breaker.
+	Breakers *Breakers
+	// CABundle is a PEM file of extra certificate authorities to trust.
+	CABundle string
+	// ProxyURL is the outbound proxy, if any.
+	ProxyURL string
+	// InsecureSkipVerify disables TLS verification and should only be used
+	// against test servers.
+	InsecureSkipVerify bool
+	// DialControl, when set, can veto each connection after DNS resolution.
+	DialControl func(network, address string, c syscall.RawConn) error
+	// CheckRedirect, when set, replaces the default redirect policy.
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+}
+
+func DefaultConfig(name string) Config {
+	return Config{
+		Name:             name,
+		Timeout:          DefaultTimeout,
+		DialTimeout:      DefaultDialTimeout,
+		HandshakeTimeout: DefaultHandshakeTimeout,
+		MaxRetries:       DefaultMaxRetries,
+		RetryBaseDelay:   DefaultRetryBaseDelay,
+		RetryMaxDelay:    DefaultRetryMaxDelay,
+		BreakerThreshold: DefaultBreakerThreshold,
+		BreakerCooldown:  DefaultBreakerCooldown,
+	}
+}
+
+// NewClient returns a client for config that retries idempotent requests and,
+// when config has Breakers, fails fast while the upstream host is down.
+func NewClient(config Config) (HTTPClient, error) {
+	transport, err := NewTransport(config)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	httpClient := &http.Client{
+		Timeout:       config.Timeout,
+		Transport:     transport,
+		CheckRedirect: config.CheckRedirect,
+	}
+
+	retryClient := NewRetryClient(httpClient, config)
+	if config.Breakers == nil || config.BreakerThreshold < 1 {
+		return retryClient, nil
+	}
+
+	return NewBreakerClient(retryClient, config.Breakers, config), nil
+}
+
+// NewTransport builds the transport for config, verifying TLS against the
+// system roots plus config.CABundle.
+func NewTransport(config Config) (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if config.CABundle != "" {
+		rootCAs, err := loadCABundle(config.CABundle)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if config.InsecureSkipVerify {
+		log.Warnf("TLS verification is disabled for %s", config.Name)
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	var proxy func(*http.Request) (*url.URL, error)
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, errors.NotValidf("outbound proxy %q for %s", config.ProxyURL, config.Name)
+		}
+
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+		Control:   config.DialControl,
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.HandshakeTimeout,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}, nil
+}
+
+func loadCABundle(path string) (*x509.CertPool, error) {
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Annotatef(err, "could not read CA bundle %s", path)
+	}
+
+	if !rootCAs.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.NotValidf("CA bundle %s", path)
+	}
+
+	return rootCAs, nil
+}